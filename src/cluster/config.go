@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"net/url"
+	"time"
+)
+
+// MasterConfig is the effective configuration of a running master, as
+// reported to `gcluster config`. Secrets are redacted before it is sent.
+type MasterConfig struct {
+	Addr            string        `json:"addr"`
+	StatePath       string        `json:"state_path,omitempty"`
+	Model           string        `json:"model,omitempty"`
+	PromptVia       string        `json:"prompt_via,omitempty"`
+	TLS             bool          `json:"tls"`
+	HealthAddr      string        `json:"health_addr,omitempty"`
+	Webhook         string        `json:"webhook,omitempty"`
+	UnhealthyWindow int           `json:"unhealthy_window"`
+	MaxFailures     int           `json:"max_failures"`
+	PushInterval    time.Duration `json:"push_interval"`
+	Limits          ApplyLimits   `json:"limits"`
+}
+
+// SetMasterConfig records the settings the server doesn't own itself —
+// state path, model, prompt delivery and health address — so config
+// requests can report them. The remaining fields of c are ignored and
+// filled from the server.
+func (s *Server) SetMasterConfig(c MasterConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = c
+}
+
+// effectiveConfig combines the recorded settings with the server's own.
+func (s *Server) effectiveConfig() MasterConfig {
+	s.mu.Lock()
+	c := s.settings
+	s.mu.Unlock()
+
+	c.Addr = s.Addr()
+	c.TLS = s.tlsConfig != nil
+	c.Webhook = ""
+	if w := s.webhook.Load(); w != nil {
+		c.Webhook = redactURL(w.URL)
+	}
+	c.UnhealthyWindow = s.unhealthyWindow
+	if s.executor != nil {
+		c.MaxFailures = s.executor.MaxConsecutiveFailures()
+	}
+	c.PushInterval = s.pushInterval
+	c.Limits = s.limits
+	return c
+}
+
+// redactURL reduces a URL to its scheme and host. Webhook services put
+// their tokens in the userinfo, the query or, like Slack and Discord, the
+// path, so anything past the host is replaced.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "REDACTED"
+	}
+	s := u.Scheme + "://" + u.Host
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		s += "/REDACTED"
+	}
+	return s
+}
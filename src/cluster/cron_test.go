@@ -0,0 +1,49 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	base := time.Date(2025, 6, 14, 10, 30, 0, 0, time.UTC) // a Saturday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 6, 14, 10, 31, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2025, 6, 15, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 6, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2025, 6, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 10 14 6 *", time.Date(2026, 6, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches (the 20th, or a Monday).
+		{"0 0 20 * 1", time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := ParseSchedule(c.expr)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", c.expr, err)
+		}
+		if got := s.Next(base); !got.Equal(c.want) {
+			t.Errorf("%q: Next = %v, want %v", c.expr, got, c.want)
+		}
+	}
+
+	never, err := ParseSchedule("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := never.Next(base); !got.IsZero() {
+		t.Errorf("Feb 31 should never trigger, got %v", got)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) should fail", expr)
+		}
+	}
+}
@@ -22,8 +22,11 @@
 //     waits for the goroutine to finish (bounded by a deadline).
 //
 //   - On claude failure mid-iteration, the error is recorded on the
-//     IterationResult and the agent continues to the next iteration.
-//     The agent only stops when explicitly stopped or the executor shuts down.
+//     IterationResult and the agent backs off, then continues to the next
+//     iteration. Besides an explicit stop or executor shutdown, an agent
+//     stops itself when it reaches its iteration cap, when too many
+//     iterations fail in a row, or when a loopless pipeline finishes or a
+//     setup step fails.
 //
 //   - Multi-step pipelines execute simple and map steps sequentially as setup,
 //     threading each step's output into the next. The final step (typically a
@@ -38,6 +41,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -58,6 +62,38 @@ type ConvoMessage struct {
 // Production code provides a function that calls the claude CLI; tests provide a fake.
 type ClaudeFunc func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error)
 
+// Usage is the token usage and cost of one or more claude calls.
+type Usage struct {
+	InputTokens  int64   `json:"input_tokens,omitempty"`
+	OutputTokens int64   `json:"output_tokens,omitempty"`
+	CostUSD      float64 `json:"cost_usd,omitempty"`
+}
+
+func (u *Usage) add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CostUSD += o.CostUSD
+}
+
+// ClaudeResult is the outcome of a claude call that reports its usage.
+type ClaudeResult struct {
+	Output string
+	Usage
+}
+
+// ClaudeUsageFunc is a ClaudeFunc that also reports token usage and cost.
+// The master uses it so steer clients can show what each agent spends.
+type ClaudeUsageFunc func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (ClaudeResult, error)
+
+// withUsage adapts a ClaudeFunc that doesn't report usage; its calls
+// count as free.
+func (f ClaudeFunc) withUsage() ClaudeUsageFunc {
+	return func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (ClaudeResult, error) {
+		out, err := f(ctx, prompt, onMessage)
+		return ClaudeResult{Output: out}, err
+	}
+}
+
 // IterationResult records the outcome of a single loop iteration.
 type IterationResult struct {
 	// Iteration is the 1-based iteration number.
@@ -70,8 +106,30 @@ type IterationResult struct {
 	Messages []ConvoMessage `json:"messages,omitempty"`
 	// Error is the error message if claude failed (empty on success).
 	Error string `json:"error,omitempty"`
+	// Usage is the tokens and cost the iteration's claude call reported.
+	Usage
 }
 
+// StepResult records the outcome of a one-shot (simple or map) pipeline
+// step that ran before the loop, so steer clients can show a timeline of
+// the setup steps above the loop's iterations.
+type StepResult struct {
+	// Label is the step's pipeline label; Method the method it ran.
+	Label  string           `json:"label"`
+	Method string           `json:"method"`
+	Kind   PipelineStepKind `json:"kind"`
+	// StartedAt and FinishedAt bound the step's execution.
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// OutputPreview is the start of the step's output (empty on failure).
+	OutputPreview string `json:"output_preview,omitempty"`
+	// Error is the failure message (empty on success).
+	Error string `json:"error,omitempty"`
+}
+
+// stepPreviewLen bounds StepResult.OutputPreview.
+const stepPreviewLen = 200
+
 // methodUpdate carries a method body update from a steer client to a running
 // agent's loop goroutine. The agent drains these between iterations and replaces
 // its base prompt so all future iterations use the new text.
@@ -92,6 +150,9 @@ type AgentRun struct {
 	// Iterations records the outcome of each completed iteration.
 	// Protected by mu.
 	Iterations []IterationResult
+	// SetupSteps records the simple/map steps run before the loop.
+	// Protected by mu.
+	SetupSteps []StepResult
 
 	// injectCh receives steering messages from steer clients. The runAgent
 	// goroutine drains this channel between iterations and prepends the
@@ -107,6 +168,24 @@ type AgentRun struct {
 	// Protected by mu.
 	liveIter *IterationResult
 
+	// iterCancel aborts the current claude call without stopping the loop;
+	// skipped records that an operator asked for it. Protected by mu.
+	iterCancel context.CancelFunc
+	skipped    bool
+
+	// delay is the pause between loop iterations, set live by steer
+	// clients to throttle the agent (zero: none). Protected by mu.
+	// delayCh wakes a pause in progress when delay changes.
+	delay   time.Duration
+	delayCh chan struct{}
+
+	// nextRun is when a scheduled agent next runs (zero while running or
+	// for continuous agents). Protected by mu.
+	nextRun time.Time
+
+	// usage totals the run's claude calls. Protected by mu.
+	usage Usage
+
 	// cancel stops this agent's goroutine.
 	cancel context.CancelFunc
 	// done is closed when the agent goroutine exits.
@@ -122,6 +201,25 @@ func (r *AgentRun) addIteration(ir IterationResult) {
 	r.Iterations = append(r.Iterations, ir)
 }
 
+// addSetupStep appends a setup step result to the run's history.
+func (r *AgentRun) addSetupStep(sr StepResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.SetupSteps = append(r.SetupSteps, sr)
+}
+
+// SnapshotSetupSteps returns a copy of all setup step results.
+func (r *AgentRun) SnapshotSetupSteps() []StepResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.SetupSteps) == 0 {
+		return nil
+	}
+	cp := make([]StepResult, len(r.SetupSteps))
+	copy(cp, r.SetupSteps)
+	return cp
+}
+
 // CurrentIteration returns the number of completed iterations.
 func (r *AgentRun) CurrentIteration() int {
 	r.mu.Lock()
@@ -129,6 +227,22 @@ func (r *AgentRun) CurrentIteration() int {
 	return len(r.Iterations)
 }
 
+// hasIteration reports whether iteration n has started: it is in the
+// history or is the one in progress.
+func (r *AgentRun) hasIteration(n int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.liveIter != nil && r.liveIter.Iteration == n {
+		return true
+	}
+	for _, ir := range r.Iterations {
+		if ir.Iteration == n {
+			return true
+		}
+	}
+	return false
+}
+
 // SnapshotIterations returns a copy of all iteration results.
 func (r *AgentRun) SnapshotIterations() []IterationResult {
 	r.mu.Lock()
@@ -169,6 +283,101 @@ func (r *AgentRun) ClearLiveIter() {
 	r.liveIter = nil
 }
 
+// setDelay changes the pause between iterations and wakes a pause in
+// progress so it is re-measured against the new value.
+func (r *AgentRun) setDelay(d time.Duration) {
+	r.mu.Lock()
+	r.delay = d
+	r.mu.Unlock()
+	select {
+	case r.delayCh <- struct{}{}:
+	default:
+	}
+}
+
+// addUsage adds a claude call's usage to the run's totals.
+func (r *AgentRun) addUsage(u Usage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage.add(u)
+}
+
+// Usage returns the total usage of the run's claude calls so far.
+func (r *AgentRun) Usage() Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage
+}
+
+func (r *AgentRun) setNextRun(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextRun = t
+}
+
+// NextRun returns when a scheduled agent next runs, or the zero time.
+func (r *AgentRun) NextRun() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.nextRun
+}
+
+// Delay returns the pause between iterations.
+func (r *AgentRun) Delay() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.delay
+}
+
+// waitDelay pauses until the run's delay has passed since last. It returns
+// false if ctx is cancelled first.
+func (r *AgentRun) waitDelay(ctx context.Context, last time.Time) bool {
+	for {
+		remaining := r.Delay() - time.Since(last)
+		if remaining <= 0 {
+			return true
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-r.delayCh:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// setIterCancel installs the cancel func for the claude call about to start.
+func (r *AgentRun) setIterCancel(cancel context.CancelFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.iterCancel = cancel
+	r.skipped = false
+}
+
+// clearIterCancel drops the current call's cancel func and reports whether
+// the call was skipped by an operator.
+func (r *AgentRun) clearIterCancel() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.iterCancel = nil
+	return r.skipped
+}
+
+// skip cancels the current claude call, if one is in flight.
+func (r *AgentRun) skip() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.iterCancel == nil {
+		return false
+	}
+	r.skipped = true
+	r.iterCancel()
+	return true
+}
+
 // SnapshotLiveIter returns a copy of the live iteration, or nil if none.
 func (r *AgentRun) SnapshotLiveIter() *IterationResult {
 	r.mu.Lock()
@@ -192,20 +401,32 @@ type AgentRunSnapshot struct {
 	StartedAt  time.Time         `json:"started_at"`
 	Iterations []IterationResult `json:"iterations"`
 	LiveIter   *IterationResult  `json:"live_iter,omitempty"`
+	SetupSteps []StepResult      `json:"setup_steps,omitempty"`
+	Delay      time.Duration     `json:"delay,omitempty"`
+	NextRun    time.Time         `json:"next_run,omitzero"`
+	// Usage totals every claude call the run has made: setup steps, map
+	// items and all iterations, not only those in Iterations.
+	Usage Usage `json:"usage,omitzero"`
 }
 
 // Executor manages the lifecycle of running agent goroutines.
 // It is owned by the Server and operates on the shared Store.
 type Executor struct {
 	store    *Store
-	claudeFn ClaudeFunc
+	claudeFn ClaudeUsageFunc
 	rootCtx  context.Context
 	rootStop context.CancelFunc
 
 	mu          sync.Mutex
-	runs        map[string]*AgentRun   // keyed by agent name
-	pipelines   map[string]*PipelineDef // keyed by agent name, cached from apply
-	onIteration func(agentName string)  // called after each iteration completes
+	runs        map[string]*AgentRun              // keyed by agent name
+	pipelines   map[string]*PipelineDef           // keyed by agent name, cached from apply
+	sinks       map[string]string                 // keyed by agent name, output sink paths from apply
+	schedules   map[string]Scheduler              // keyed by agent name, for scheduled agents
+	maxIters    map[string]int                    // keyed by agent name, iteration caps for agents without a pipeline
+	backoff     Backoff                           // pause after failed iterations
+	maxFailures int                               // consecutive failed iterations that stop an agent; 0 means never
+	onIteration func(agentName string)            // called after each iteration completes
+	onFinish    func(agentName string, err error) // called when a pipeline ends on its own
 
 	pushMu   sync.Mutex
 	lastPush map[string]time.Time // throttle streaming pushes per agent
@@ -215,6 +436,12 @@ type Executor struct {
 // function. The rootCtx should be derived from the server's shutdown context;
 // cancelling it will stop all running agents.
 func NewExecutor(store *Store, claudeFn ClaudeFunc) *Executor {
+	return NewExecutorWithUsage(store, claudeFn.withUsage())
+}
+
+// NewExecutorWithUsage is NewExecutor for a claude function that reports
+// usage, which is recorded per iteration and totalled per run.
+func NewExecutorWithUsage(store *Store, claudeFn ClaudeUsageFunc) *Executor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Executor{
 		store:     store,
@@ -223,10 +450,63 @@ func NewExecutor(store *Store, claudeFn ClaudeFunc) *Executor {
 		rootStop:  cancel,
 		runs:      make(map[string]*AgentRun),
 		pipelines: make(map[string]*PipelineDef),
+		sinks:     make(map[string]string),
+		schedules: make(map[string]Scheduler),
+		maxIters:  make(map[string]int),
+		backoff:   DefaultBackoff,
 		lastPush:  make(map[string]time.Time),
 	}
 }
 
+// Backoff is how long a loop waits after consecutive failed iterations
+// before trying again: Initial after the first failure, doubling after
+// each further one, never more than Max. A zero Initial disables it.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// DefaultBackoff waits 1s, 2s, 4s, ... up to 30s between failed iterations.
+var DefaultBackoff = Backoff{Initial: time.Second, Max: 30 * time.Second}
+
+// after returns the wait following the given number of consecutive failures.
+func (b Backoff) after(failures int) time.Duration {
+	if b.Initial <= 0 || failures < 1 {
+		return 0
+	}
+	d := b.Initial
+	for i := 1; i < failures && (b.Max <= 0 || d < b.Max); i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	return d
+}
+
+// SetMaxConsecutiveFailures makes an agent stop itself once n iterations
+// in a row have failed. 0, the default, lets it retry forever.
+func (e *Executor) SetMaxConsecutiveFailures(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.maxFailures = n
+}
+
+// MaxConsecutiveFailures returns the limit set by SetMaxConsecutiveFailures.
+func (e *Executor) MaxConsecutiveFailures() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.maxFailures
+}
+
+// SetBackoff replaces the wait applied after failed iterations. It takes
+// effect from the next failure.
+func (e *Executor) SetBackoff(b Backoff) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.backoff = b
+}
+
 // SetPipeline caches a pipeline definition for an agent. Called by the server
 // when processing apply requests so the executor knows the step structure.
 func (e *Executor) SetPipeline(name string, p *PipelineDef) {
@@ -237,6 +517,26 @@ func (e *Executor) SetPipeline(name string, p *PipelineDef) {
 	}
 }
 
+// Scheduled reports whether an agent runs on a schedule.
+func (e *Executor) Scheduled(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.schedules[name] != nil
+}
+
+// SetSchedule makes an agent scheduled: from its next Start it runs its
+// pipeline once per trigger of sched rather than continuously. A nil sched
+// makes it continuous again. Called by the server when processing applies.
+func (e *Executor) SetSchedule(name string, sched Scheduler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if sched == nil {
+		delete(e.schedules, name)
+		return
+	}
+	e.schedules[name] = sched
+}
+
 // Start launches execution of an agent. It transitions the agent from pending
 // to running in the store and spawns the execution goroutine.
 //
@@ -259,9 +559,14 @@ func (e *Executor) Start(name string, methods map[string]string) error {
 		return fmt.Errorf("agent %q not found in store", name)
 	}
 
-	// Grab cached pipeline def if available.
+	// Grab cached pipeline def and schedule if available.
 	pdef := e.pipelines[name]
+	sched := e.schedules[name]
+	maxIters := e.maxIters[name]
 	e.mu.Unlock()
+	if sched != nil && (pdef == nil || pdef.loops()) {
+		return fmt.Errorf("agent %q: a scheduled agent needs a pipeline without a loop step", name)
+	}
 
 	// Transition to running in the store. This is done outside the executor
 	// lock because SetRunState triggers Store.OnChange, which may call
@@ -284,6 +589,7 @@ func (e *Executor) Start(name string, methods map[string]string) error {
 		RevisionID: obj.CurrentRevision,
 		StartedAt:  time.Now(),
 		injectCh:   make(chan string, 32),
+		delayCh:    make(chan struct{}, 1),
 		methodCh:   make(chan methodUpdate, 4),
 		cancel:     agentCancel,
 		done:       make(chan struct{}),
@@ -303,7 +609,11 @@ func (e *Executor) Start(name string, methods map[string]string) error {
 			close(run.done)
 			return fmt.Errorf("agent %q: invalid pipeline: %w", name, err)
 		}
-		go e.runPipeline(agentCtx, run, pdef, methods)
+		if sched != nil {
+			go e.runScheduled(agentCtx, run, pdef, methods, sched)
+		} else {
+			go e.runPipeline(agentCtx, run, pdef, methods)
+		}
 	} else {
 		// Legacy single-method path.
 		prompt, err := e.resolvePrompt(methods)
@@ -318,7 +628,7 @@ func (e *Executor) Start(name string, methods map[string]string) error {
 		}
 		go func() {
 			defer close(run.done)
-			e.runAgentLoop(agentCtx, run, prompt, prompt)
+			e.runAgentLoop(agentCtx, run, prompt, prompt, maxIters)
 		}()
 	}
 
@@ -341,6 +651,11 @@ func (e *Executor) validatePipeline(p *PipelineDef, methods map[string]string) e
 			methodName = step.LoopMethod
 		case StepKindMap:
 			methodName = step.MapMethod
+		case StepKindReduce:
+			if i == 0 && p.InitialInput == "" {
+				return fmt.Errorf("step %d (%s): reduce needs a previous step to reduce", i+1, step.Label)
+			}
+			methodName = step.ReduceMethod
 		default:
 			return fmt.Errorf("step %d (%s): unknown kind %q", i+1, step.Label, step.Kind)
 		}
@@ -350,10 +665,55 @@ func (e *Executor) validatePipeline(p *PipelineDef, methods map[string]string) e
 		if _, ok := methods[methodName]; !ok {
 			return fmt.Errorf("step %d (%s): method %q not found in resolved methods", i+1, step.Label, methodName)
 		}
+		if step.WarmupMethod != "" {
+			if _, ok := methods[step.WarmupMethod]; !ok {
+				return fmt.Errorf("step %d (%s): warmup method %q not found in resolved methods", i+1, step.Label, step.WarmupMethod)
+			}
+		}
 	}
 	return nil
 }
 
+// SetOutputSink sets (or, with an empty path, clears) the file an agent's
+// successful iteration outputs are appended to. Called by the server when
+// processing apply requests; the path must already be validated.
+func (e *Executor) SetOutputSink(name, path string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if path == "" {
+		delete(e.sinks, name)
+		return
+	}
+	e.sinks[name] = path
+}
+
+// SetMaxIterations caps (or, with 0, uncaps) the iterations of an agent
+// without a pipeline. Called by the server when processing apply requests;
+// pipeline agents carry their cap on the loop step.
+func (e *Executor) SetMaxIterations(name string, n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if n <= 0 {
+		delete(e.maxIters, name)
+		return
+	}
+	e.maxIters[name] = n
+}
+
+// writeSink appends an iteration's output to the agent's sink, if any.
+// Sink failures are logged but never fail the iteration.
+func (e *Executor) writeSink(agentName string, iteration int, output string) {
+	e.mu.Lock()
+	path := e.sinks[agentName]
+	e.mu.Unlock()
+	if path == "" {
+		return
+	}
+	if err := appendOutput(path, iteration, output); err != nil {
+		log.Printf("executor: agent %q: write output sink %s: %v", agentName, path, err)
+	}
+}
+
 // resolvePrompt extracts a single prompt from the methods map for legacy
 // single-method agents (no PipelineDef available).
 func (e *Executor) resolvePrompt(methods map[string]string) (string, error) {
@@ -370,8 +730,10 @@ func (e *Executor) resolvePrompt(methods map[string]string) (string, error) {
 
 // runPipeline executes a multi-step pipeline. Steps before the final loop
 // run once (simple) or fan-out (map), threading output between steps.
-// The final step, if a loop, runs forever with iteration tracking.
-// If all steps are non-loop, the pipeline runs once to completion.
+// The final step, if a loop, runs with iteration tracking until the agent
+// is stopped, reaches the loop's iteration cap or fails too many
+// iterations in a row. If all steps are non-loop, the pipeline runs once
+// to completion.
 //
 // Why iteration tracking only covers the loop step: simple and map steps
 // are one-shot setup. The loop step is where the agent does ongoing work,
@@ -379,10 +741,70 @@ func (e *Executor) resolvePrompt(methods map[string]string) (string, error) {
 // iterations keeps the model simple and matches the TUI's expectations.
 func (e *Executor) runPipeline(ctx context.Context, run *AgentRun, p *PipelineDef, methods map[string]string) {
 	defer close(run.done)
+	e.runSteps(ctx, run, p, methods)
+}
+
+// reduce folds items into one, left to right: each call gets the result so
+// far and the next item, separated as a map step separates its results,
+// followed by body. A single item is returned as-is without calling claude.
+func (e *Executor) reduce(ctx context.Context, run *AgentRun, items []string, body string) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("reduce got 0 items from previous output")
+	}
+	acc := items[0]
+	for j, item := range items[1:] {
+		prompt := acc + mapSeparator + item + "\n\n" + body
+		res, err := e.claudeFn(ctx, e.interpolateScratch(prompt), nil)
+		run.addUsage(res.Usage)
+		if err != nil {
+			return "", fmt.Errorf("reduce item %d: %w", j+2, err)
+		}
+		acc = res.Output
+	}
+	return acc, nil
+}
+
+// runScheduled runs a loopless pipeline once per trigger of sched until ctx
+// is cancelled. Between runs the agent is in RunStateScheduled.
+func (e *Executor) runScheduled(ctx context.Context, run *AgentRun, p *PipelineDef, methods map[string]string, sched Scheduler) {
+	defer close(run.done)
+	for {
+		next := sched.Next(time.Now())
+		run.setNextRun(next)
+		e.store.SetRunState(run.Name, RunStateScheduled)
+		if next.IsZero() {
+			log.Printf("executor: agent %q schedule never triggers", run.Name)
+			<-ctx.Done()
+			return
+		}
+		log.Printf("executor: agent %q next run at %s", run.Name, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
 
+		run.setNextRun(time.Time{})
+		e.store.SetRunState(run.Name, RunStateRunning)
+		e.runSteps(ctx, run, p, methods)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// runSteps executes the pipeline's steps once; see runPipeline.
+func (e *Executor) runSteps(ctx context.Context, run *AgentRun, p *PipelineDef, methods map[string]string) {
 	var prevOutput string
+	// prevItems holds a map step's results for a following map or reduce.
+	var prevItems []string
 
 	for i, step := range p.Steps {
+		mapped := prevItems
+		prevItems = nil
 		// Check cancellation between steps.
 		select {
 		case <-ctx.Done():
@@ -400,12 +822,18 @@ func (e *Executor) runPipeline(ctx context.Context, run *AgentRun, p *PipelineDe
 			}
 
 			log.Printf("executor: agent %q running simple step %d/%d (%s)", run.Name, i+1, len(p.Steps), step.Label)
-			output, err := e.claudeFn(ctx, prompt, nil)
+			sr := StepResult{Label: step.Label, Method: step.Method, Kind: step.Kind, StartedAt: time.Now()}
+			res, err := e.claudeFn(ctx, e.interpolateScratch(prompt), nil)
+			run.addUsage(res.Usage)
+			output := res.Output
+			sr.FinishedAt = time.Now()
+			if ctx.Err() != nil {
+				log.Printf("executor: agent %q step %d (%s) cancelled", run.Name, i+1, step.Label)
+				return
+			}
 			if err != nil {
-				if ctx.Err() != nil {
-					log.Printf("executor: agent %q step %d (%s) cancelled", run.Name, i+1, step.Label)
-					return
-				}
+				sr.Error = err.Error()
+				run.addSetupStep(sr)
 				// Setup step failure aborts the pipeline. Record it as a
 				// failed iteration so steer clients can see what happened.
 				log.Printf("executor: agent %q step %d (%s) failed: %v — pipeline aborted", run.Name, i+1, step.Label, err)
@@ -416,14 +844,22 @@ func (e *Executor) runPipeline(ctx context.Context, run *AgentRun, p *PipelineDe
 					Error:      fmt.Sprintf("pipeline step %d (%s): %v", i+1, step.Label, err),
 				})
 				e.fireOnIteration(run.Name)
+				e.fireOnFinish(run.Name, fmt.Errorf("pipeline step %d (%s): %w", i+1, step.Label, err))
 				return
 			}
+			e.writeScratch(run.Name, output)
+			sr.OutputPreview = preview(output)
+			run.addSetupStep(sr)
+			e.fireOnIteration(run.Name)
 			prevOutput = output
 			log.Printf("executor: agent %q step %d (%s) complete (%d bytes)", run.Name, i+1, step.Label, len(output))
 
 		case StepKindMap:
 			body := methods[step.MapMethod]
-			items := splitItems(prevOutput)
+			items := mapped
+			if items == nil {
+				items = splitItems(prevOutput)
+			}
 			if len(items) == 0 {
 				log.Printf("executor: agent %q step %d (%s): map got 0 items — pipeline aborted", run.Name, i+1, step.Label)
 				run.addIteration(IterationResult{
@@ -433,39 +869,61 @@ func (e *Executor) runPipeline(ctx context.Context, run *AgentRun, p *PipelineDe
 					Error:      fmt.Sprintf("pipeline step %d (%s): map got 0 items from previous output", i+1, step.Label),
 				})
 				e.fireOnIteration(run.Name)
+				e.fireOnFinish(run.Name, fmt.Errorf("pipeline step %d (%s): map got 0 items from previous output", i+1, step.Label))
 				return
 			}
 
 			log.Printf("executor: agent %q running map step %d/%d (%s) with %d items", run.Name, i+1, len(p.Steps), step.Label, len(items))
+			sr := StepResult{Label: step.Label, Method: step.MapMethod, Kind: step.Kind, StartedAt: time.Now()}
 
 			results := make([]string, len(items))
 			var mu sync.Mutex
 			var wg sync.WaitGroup
 			var firstErr error
 
+			// sem bounds in-flight items when the step sets a limit.
+			var sem chan struct{}
+			if step.MaxMapConcurrency > 0 {
+				sem = make(chan struct{}, step.MaxMapConcurrency)
+			}
 			mapCtx, mapCancel := context.WithCancel(ctx)
 			for j, item := range items {
 				wg.Add(1)
 				go func(idx int, itemText string) {
 					defer wg.Done()
+					if sem != nil {
+						select {
+						case sem <- struct{}{}:
+							defer func() { <-sem }()
+						case <-mapCtx.Done():
+							return // an earlier item failed or the agent stopped
+						}
+					}
 					prompt := itemText + "\n\n" + body
-					result, err := e.claudeFn(mapCtx, prompt, nil)
+					res, err := e.claudeFn(mapCtx, e.interpolateScratch(prompt), nil)
+					run.addUsage(res.Usage)
 					mu.Lock()
 					defer mu.Unlock()
 					if err != nil && firstErr == nil {
 						firstErr = fmt.Errorf("map item %d: %w", idx+1, err)
 						mapCancel() // cancel remaining items on first error
 					}
-					results[idx] = result
+					results[idx] = res.Output
 				}(j, item)
 			}
 			wg.Wait()
 			mapCancel() // ensure cancel is always called
+			sr.FinishedAt = time.Now()
 
+			// Stopped mid-map: items may have returned partial output
+			// without an error, so don't join or record anything.
+			if ctx.Err() != nil {
+				log.Printf("executor: agent %q step %d (%s) map cancelled", run.Name, i+1, step.Label)
+				return
+			}
 			if firstErr != nil {
-				if ctx.Err() != nil {
-					return
-				}
+				sr.Error = firstErr.Error()
+				run.addSetupStep(sr)
 				log.Printf("executor: agent %q step %d (%s) map failed: %v — pipeline aborted", run.Name, i+1, step.Label, firstErr)
 				run.addIteration(IterationResult{
 					Iteration:  1,
@@ -474,27 +932,79 @@ func (e *Executor) runPipeline(ctx context.Context, run *AgentRun, p *PipelineDe
 					Error:      fmt.Sprintf("pipeline step %d (%s): %v", i+1, step.Label, firstErr),
 				})
 				e.fireOnIteration(run.Name)
+				e.fireOnFinish(run.Name, fmt.Errorf("pipeline step %d (%s): %w", i+1, step.Label, firstErr))
 				return
 			}
-			prevOutput = strings.Join(results, "\n\n---\n\n")
+			// Scratch writes land in item order, so a later item's value
+			// for a key wins regardless of which call finished first.
+			for _, out := range results {
+				e.writeScratch(run.Name, out)
+			}
+			prevOutput = strings.Join(results, mapSeparator)
+			prevItems = results
+			sr.OutputPreview = preview(prevOutput)
+			run.addSetupStep(sr)
+			e.fireOnIteration(run.Name)
 			log.Printf("executor: agent %q step %d (%s) map complete (%d items)", run.Name, i+1, step.Label, len(items))
 
+		case StepKindReduce:
+			items := mapped
+			if items == nil {
+				items = splitItems(prevOutput)
+			}
+			log.Printf("executor: agent %q running reduce step %d/%d (%s) over %d items", run.Name, i+1, len(p.Steps), step.Label, len(items))
+			sr := StepResult{Label: step.Label, Method: step.ReduceMethod, Kind: step.Kind, StartedAt: time.Now()}
+			output, err := e.reduce(ctx, run, items, methods[step.ReduceMethod])
+			sr.FinishedAt = time.Now()
+			if ctx.Err() != nil {
+				log.Printf("executor: agent %q step %d (%s) reduce cancelled", run.Name, i+1, step.Label)
+				return
+			}
+			if err != nil {
+				sr.Error = err.Error()
+				run.addSetupStep(sr)
+				log.Printf("executor: agent %q step %d (%s) reduce failed: %v — pipeline aborted", run.Name, i+1, step.Label, err)
+				run.addIteration(IterationResult{
+					Iteration:  1,
+					StartedAt:  time.Now(),
+					FinishedAt: time.Now(),
+					Error:      fmt.Sprintf("pipeline step %d (%s): %v", i+1, step.Label, err),
+				})
+				e.fireOnIteration(run.Name)
+				e.fireOnFinish(run.Name, fmt.Errorf("pipeline step %d (%s): %w", i+1, step.Label, err))
+				return
+			}
+			e.writeScratch(run.Name, output)
+			sr.OutputPreview = preview(output)
+			run.addSetupStep(sr)
+			e.fireOnIteration(run.Name)
+			prevOutput = output
+			log.Printf("executor: agent %q step %d (%s) reduce complete (%d items)", run.Name, i+1, step.Label, len(items))
+
 		case StepKindLoop:
 			body := methods[step.LoopMethod]
-			// First iteration gets previous step output as context.
+			// First iteration gets previous step output as context, and the
+			// warmup method's body in place of the loop body if one is set.
 			// Subsequent iterations use just the method body (plus steering).
 			firstPrompt := body
+			if step.WarmupMethod != "" {
+				firstPrompt = methods[step.WarmupMethod]
+			}
 			if prevOutput != "" {
-				firstPrompt = prevOutput + "\n\n" + body
+				firstPrompt = prevOutput + "\n\n" + firstPrompt
 			}
 			log.Printf("executor: agent %q entering loop step %d/%d (%s)", run.Name, i+1, len(p.Steps), step.Label)
-			e.runAgentLoop(ctx, run, firstPrompt, body)
-			return // loop never finishes normally
+			e.runAgentLoop(ctx, run, firstPrompt, body, step.MaxIterations)
+			return // loop only finishes by hitting its cap
 		}
 	}
 
 	// Pipeline completed with no loop step (all simple/map).
+	if ctx.Err() != nil {
+		return
+	}
 	log.Printf("executor: agent %q pipeline complete (no loop step)", run.Name)
+	e.fireOnFinish(run.Name, nil)
 }
 
 // runAgentLoop is the inner loop for a loop step. It calls claude repeatedly
@@ -506,18 +1016,33 @@ func (e *Executor) runPipeline(ctx context.Context, run *AgentRun, p *PipelineDe
 //
 // This is also the execution path for legacy single-method agents where
 // firstPrompt == basePrompt.
-func (e *Executor) runAgentLoop(ctx context.Context, run *AgentRun, firstPrompt string, basePrompt string) {
+//
+// If maxIterations is positive, the loop ends after that many iterations
+// and the agent is stopped as if by Stop.
+func (e *Executor) runAgentLoop(ctx context.Context, run *AgentRun, firstPrompt string, basePrompt string, maxIterations int) {
 	iteration := 0
+	failures := 0 // consecutive failed iterations, for backoff
+	var lastFinished time.Time
 	for {
 		iteration++
+		if maxIterations > 0 && iteration > maxIterations {
+			log.Printf("executor: agent %q reached its cap of %d iterations", run.Name, maxIterations)
+			e.finishRun(run, nil)
+			return
+		}
 
-		// Check for cancellation before starting iteration.
+		// Check for cancellation before starting iteration, and honour
+		// any operator-set delay since the previous one.
 		select {
 		case <-ctx.Done():
 			log.Printf("executor: agent %q stopped before iteration %d", run.Name, iteration)
 			return
 		default:
 		}
+		if iteration > 1 && !run.waitDelay(ctx, lastFinished) {
+			log.Printf("executor: agent %q stopped before iteration %d", run.Name, iteration)
+			return
+		}
 
 		iterPrompt := basePrompt
 		if iteration == 1 {
@@ -567,6 +1092,7 @@ func (e *Executor) runAgentLoop(ctx context.Context, run *AgentRun, firstPrompt
 				break drainMethods
 			}
 		}
+		iterPrompt = e.interpolateScratch(iterPrompt)
 
 		ir := IterationResult{
 			Iteration: iteration,
@@ -575,14 +1101,27 @@ func (e *Executor) runAgentLoop(ctx context.Context, run *AgentRun, firstPrompt
 		run.SetLiveIter(&ir)
 		e.fireOnIteration(run.Name) // TUI sees "running..." immediately
 
+		// Each call gets its own context so an operator can skip just this
+		// iteration (SkipIteration) without stopping the agent.
+		iterCtx, iterCancel := context.WithCancel(ctx)
+		run.setIterCancel(iterCancel)
+
 		log.Printf("executor: agent %q starting iteration %d", run.Name, iteration)
-		_, err := e.claudeFn(ctx, iterPrompt, func(msg ConvoMessage) {
+		res, err := e.claudeFn(iterCtx, iterPrompt, func(msg ConvoMessage) {
 			run.AppendLiveMessage(msg)
 			e.fireOnStreaming(run.Name)
 		})
+		output := res.Output
+		run.addUsage(res.Usage)
 
+		skipped := run.clearIterCancel()
+		iterCancel()
 		run.ClearLiveIter()
+		// ir is no longer shared with snapshots once the live pointer is
+		// cleared.
+		ir.Usage = res.Usage
 		ir.FinishedAt = time.Now()
+		lastFinished = ir.FinishedAt
 
 		if err != nil {
 			// Check if the error is from context cancellation (agent stopped).
@@ -592,14 +1131,48 @@ func (e *Executor) runAgentLoop(ctx context.Context, run *AgentRun, firstPrompt
 				run.addIteration(ir)
 				return
 			}
-			// Claude failed mid-iteration: record error, continue to next.
+			if skipped {
+				ir.Error = "skipped by operator"
+				run.addIteration(ir)
+				e.fireOnIteration(run.Name)
+				log.Printf("executor: agent %q iteration %d skipped by operator", run.Name, iteration)
+				continue
+			}
+			// Claude failed mid-iteration: record error, back off, then
+			// continue to next so an outage doesn't spin the loop. An
+			// agent that keeps failing stops itself.
+			failures++
+			e.mu.Lock()
+			wait := e.backoff.after(failures)
+			maxFailures := e.maxFailures
+			e.mu.Unlock()
 			ir.Error = err.Error()
+			if maxFailures > 0 && failures >= maxFailures {
+				ir.Error = fmt.Sprintf("%s (stopping after %d consecutive failures)", ir.Error, failures)
+				run.addIteration(ir)
+				log.Printf("executor: agent %q iteration %d failed: %v (stopping after %d consecutive failures)", run.Name, iteration, err, failures)
+				e.finishRun(run, fmt.Errorf("%d consecutive failed iterations, last: %w", failures, err))
+				return
+			}
 			run.addIteration(ir)
 			e.fireOnIteration(run.Name)
-			log.Printf("executor: agent %q iteration %d failed: %v (continuing)", run.Name, iteration, err)
+			log.Printf("executor: agent %q iteration %d failed: %v (retrying in %v)", run.Name, iteration, err, wait)
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					log.Printf("executor: agent %q stopped during backoff", run.Name)
+					return
+				case <-timer.C:
+				}
+			}
 			continue
 		}
+		failures = 0
 
+		e.writeScratch(run.Name, output)
+		e.writeSink(run.Name, iteration, output)
 		run.addIteration(ir)
 		e.fireOnIteration(run.Name)
 		log.Printf("executor: agent %q iteration %d complete (%d messages)", run.Name, iteration, len(ir.Messages))
@@ -616,6 +1189,31 @@ func (e *Executor) fireOnIteration(agentName string) {
 	}
 }
 
+// finishRun stops an agent whose loop ended on its own: it forgets the run,
+// marks the agent stopped and reports the pipeline as finished, with err
+// if it gave up. It does nothing if the agent was stopped in the meantime.
+func (e *Executor) finishRun(run *AgentRun, err error) {
+	e.mu.Lock()
+	if e.runs[run.Name] != run {
+		e.mu.Unlock()
+		return
+	}
+	delete(e.runs, run.Name)
+	e.mu.Unlock()
+	e.store.SetRunState(run.Name, RunStateStopped)
+	e.fireOnFinish(run.Name, err)
+}
+
+// fireOnFinish calls the onFinish callback if set.
+func (e *Executor) fireOnFinish(agentName string, err error) {
+	e.mu.Lock()
+	fn := e.onFinish
+	e.mu.Unlock()
+	if fn != nil {
+		fn(agentName, err)
+	}
+}
+
 // fireOnStreaming calls the onIteration callback with a 50ms throttle per agent,
 // preventing excessive state pushes during rapid streaming updates.
 func (e *Executor) fireOnStreaming(agentName string) {
@@ -661,6 +1259,22 @@ func (e *Executor) Stop(name string, timeout time.Duration) error {
 	return nil
 }
 
+// Remove stops the named agent if it is running and forgets its cached
+// pipeline and output sink. Used when an agent is deleted from the cluster.
+func (e *Executor) Remove(name string, timeout time.Duration) {
+	if e.IsRunning(name) {
+		if err := e.Stop(name, timeout); err != nil {
+			log.Printf("executor: remove agent %q: %v", name, err)
+		}
+	}
+	e.mu.Lock()
+	delete(e.pipelines, name)
+	delete(e.sinks, name)
+	delete(e.schedules, name)
+	delete(e.maxIters, name)
+	e.mu.Unlock()
+}
+
 // StopAll stops all running agents and waits for them to finish.
 // Used during graceful shutdown. Returns after all agents have stopped
 // or the timeout expires.
@@ -774,6 +1388,77 @@ func (e *Executor) InjectMessage(agentName string, message string) error {
 	return nil
 }
 
+// SetDelay sets the pause between the agent's loop iterations, taking
+// effect immediately (a pause in progress is shortened or extended). Zero
+// removes the pause.
+func (e *Executor) SetDelay(agentName string, delay time.Duration) error {
+	if delay < 0 {
+		return fmt.Errorf("delay must not be negative")
+	}
+	e.mu.Lock()
+	run, ok := e.runs[agentName]
+	e.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("agent %q is not running", agentName)
+	}
+	run.setDelay(delay)
+	log.Printf("executor: agent %q iteration delay set to %v", agentName, delay)
+	return nil
+}
+
+// SkipIteration aborts the agent's in-flight claude call and moves on to
+// the next iteration; the aborted one is recorded as "skipped by operator".
+// If iteration is non-zero it must match the live iteration, so a stale
+// request can't skip a newer one.
+func (e *Executor) SkipIteration(agentName string, iteration int) error {
+	e.mu.Lock()
+	run, ok := e.runs[agentName]
+	e.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("agent %q is not running", agentName)
+	}
+	live := run.SnapshotLiveIter()
+	if live == nil {
+		return fmt.Errorf("agent %q has no iteration in progress", agentName)
+	}
+	if iteration != 0 && live.Iteration != iteration {
+		return fmt.Errorf("agent %q is on iteration %d, not %d", agentName, live.Iteration, iteration)
+	}
+	if !run.skip() {
+		return fmt.Errorf("agent %q has no iteration in progress", agentName)
+	}
+	log.Printf("executor: skipping agent %q iteration %d", agentName, live.Iteration)
+	return nil
+}
+
+// Replay runs a one-off claude call with prompt so an operator can compare
+// its output against the given iteration of a running agent, which must
+// have started. It runs outside the agent's loop and is not recorded in the
+// agent's history.
+func (e *Executor) Replay(agentName string, iteration int, prompt string) (string, error) {
+	e.mu.Lock()
+	run, ok := e.runs[agentName]
+	e.mu.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("agent %q is not running", agentName)
+	}
+	if !run.hasIteration(iteration) {
+		return "", fmt.Errorf("agent %q has no iteration %d", agentName, iteration)
+	}
+	if prompt == "" {
+		return "", fmt.Errorf("replay needs a prompt")
+	}
+	ctx, cancel := context.WithCancel(e.rootCtx)
+	defer cancel()
+
+	log.Printf("executor: replaying agent %q iteration %d (%d bytes)", agentName, iteration, len(prompt))
+	res, err := e.claudeFn(ctx, prompt, nil)
+	return res.Output, err
+}
+
 // UpdateMethodBody sends a method body update to a running agent. The agent's
 // loop goroutine will pick up the change before the next iteration and replace
 // its base prompt. If the agent is not running, this is a no-op — the server's
@@ -811,6 +1496,15 @@ func (e *Executor) OnIteration(fn func(agentName string)) {
 	e.onIteration = fn
 }
 
+// OnFinish registers a callback invoked when an agent's pipeline ends
+// without being stopped: err is nil when all steps completed, or the step
+// error when the pipeline was aborted. Loop agents never finish on their own.
+func (e *Executor) OnFinish(fn func(agentName string, err error)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onFinish = fn
+}
+
 // Snapshot returns a map of agent name → run snapshot for all running agents.
 // Used by the server to include iteration data in SteerStatePayload.
 func (e *Executor) Snapshot() map[string]AgentRunSnapshot {
@@ -829,11 +1523,59 @@ func (e *Executor) Snapshot() map[string]AgentRunSnapshot {
 			StartedAt:  run.StartedAt,
 			Iterations: iters,
 			LiveIter:   run.SnapshotLiveIter(),
+			SetupSteps: run.SnapshotSetupSteps(),
+			Delay:      run.Delay(),
+			NextRun:    run.NextRun(),
+			Usage:      run.Usage(),
 		}
 	}
 	return result
 }
 
+// DefaultUnhealthyWindow is how many consecutive failed iterations mark an
+// agent unhealthy.
+const DefaultUnhealthyWindow = 5
+
+// UnhealthyAgents returns, sorted, the agents whose last window iterations
+// all failed. Such an agent is still "running" but making no progress.
+// Agents with fewer than window iterations are not reported.
+func (e *Executor) UnhealthyAgents(window int) []string {
+	if window < 1 {
+		window = 1
+	}
+	var names []string
+	for _, run := range e.runList() {
+		iters := run.SnapshotIterations()
+		if len(iters) < window {
+			continue
+		}
+		failed := true
+		for _, it := range iters[len(iters)-window:] {
+			if it.Error == "" {
+				failed = false
+				break
+			}
+		}
+		if failed {
+			names = append(names, run.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runList returns the tracked runs, so callers can read each run's
+// history under its own lock rather than e.mu.
+func (e *Executor) runList() []*AgentRun {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	runs := make([]*AgentRun, 0, len(e.runs))
+	for _, run := range e.runs {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
 // StartPending scans the store for agents in pending state and starts them.
 // This is called after applying definitions to auto-start new agents.
 // The methods argument maps agent name -> (method name -> method body).
@@ -845,7 +1587,7 @@ func (e *Executor) StartPending(agentMethods map[string]map[string]string) {
 		}
 		methods, ok := agentMethods[obj.Name]
 		if !ok {
-			log.Printf("executor: agent %q is pending but no methods provided, skipping", obj.Name)
+			log.Printf("executor: agent %q is pending with no methods to start, skipping", obj.Name)
 			continue
 		}
 		if err := e.Start(obj.Name, methods); err != nil {
@@ -854,6 +1596,21 @@ func (e *Executor) StartPending(agentMethods map[string]map[string]string) {
 	}
 }
 
+// preview truncates s to stepPreviewLen bytes for StepResult.OutputPreview.
+func preview(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > stepPreviewLen {
+		return s[:stepPreviewLen] + "…"
+	}
+	return s
+}
+
+// mapSeparator joins the results of a map step into one output. A map or
+// reduce step right after a map step takes the results as its items
+// directly rather than splitting the joined text, which may itself
+// contain markdown rules.
+const mapSeparator = "\n\n---\n\n"
+
 // splitItems splits text into items using heuristics:
 // tries numbered lists, markdown headings, bullet points, then paragraphs.
 // This is a copy of the logic from runtime/runtime.go, duplicated here
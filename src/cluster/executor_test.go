@@ -3,6 +3,8 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -177,6 +179,110 @@ func TestExecutorErrorRecovery(t *testing.T) {
 	}
 }
 
+func TestExecutorOutputSink(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "builder")
+
+	// Fail the first call: failed iterations must not reach the sink.
+	exec := NewExecutor(store, fakeClaudeFailN(1, 5*time.Millisecond))
+	exec.SetBackoff(Backoff{})
+	sink := filepath.Join(t.TempDir(), "out", "builder.log")
+	exec.SetOutputSink("builder", sink)
+
+	if err := exec.Start("builder", map[string]string{"build": "do work"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := exec.Stop("builder", 2*time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	data, err := os.ReadFile(sink)
+	if err != nil {
+		t.Fatalf("sink file should exist: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "iteration 2 ===\noutput-2") {
+		t.Errorf("sink missing iteration 2 output:\n%s", content)
+	}
+	if strings.Contains(content, "iteration 1 ===") {
+		t.Errorf("failed iteration should not be written to the sink:\n%s", content)
+	}
+}
+
+func TestValidateOutputSink(t *testing.T) {
+	for _, path := range []string{"out.log", "logs/builder.log"} {
+		if err := ValidateOutputSink(path); err != nil {
+			t.Errorf("ValidateOutputSink(%q): unexpected error %v", path, err)
+		}
+	}
+	for _, path := range []string{"/etc/passwd", "../out.log", "logs/../../out.log", ""} {
+		if err := ValidateOutputSink(path); err == nil {
+			t.Errorf("ValidateOutputSink(%q): expected error", path)
+		}
+	}
+}
+
+func TestExecutorSkipIteration(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "builder")
+
+	// The first call hangs until cancelled; later calls return quickly.
+	var calls atomic.Int64
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		select {
+		case <-time.After(5 * time.Millisecond):
+			return "ok", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	exec := NewExecutor(store, claudeFn)
+
+	if err := exec.SkipIteration("builder", 0); err == nil {
+		t.Fatal("skipping a stopped agent should fail")
+	}
+	if err := exec.Start("builder", map[string]string{"build": "do work"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer exec.Stop("builder", 2*time.Second)
+
+	run := exec.GetRun("builder")
+	deadline := time.Now().Add(2 * time.Second)
+	for run.SnapshotLiveIter() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := exec.SkipIteration("builder", 2); err == nil {
+		t.Fatal("skipping a different iteration than the live one should fail")
+	}
+	if err := exec.SkipIteration("builder", 1); err != nil {
+		t.Fatalf("SkipIteration: %v", err)
+	}
+
+	// The loop must carry on past the skipped iteration.
+	for run.CurrentIteration() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	iters := run.SnapshotIterations()
+	if len(iters) < 3 {
+		t.Fatalf("loop should continue after skip, got %d iterations", len(iters))
+	}
+	if iters[0].Error != "skipped by operator" {
+		t.Errorf("iteration 1 should be recorded as skipped, got %q", iters[0].Error)
+	}
+	if iters[1].Error != "" {
+		t.Errorf("iteration 2 should succeed, got %q", iters[1].Error)
+	}
+	if !exec.IsRunning("builder") {
+		t.Error("agent should still be running after a skip")
+	}
+}
+
 func TestExecutorStopAll(t *testing.T) {
 	store := NewStore()
 	seedAgent(store, "alpha")
@@ -674,6 +780,84 @@ func TestPipelineSimpleStepFailure(t *testing.T) {
 
 // TestPipelineValidation verifies that Start fails if the pipeline references
 // a method that wasn't provided in the methods map.
+// TestPipelineSetupStepResults verifies that simple and map steps record
+// timing and output previews, that a failing step records its error, and
+// that both reach the run snapshot.
+func TestPipelineSetupStepResults(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "ralph")
+
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		switch {
+		case strings.Contains(prompt, "list ideas"):
+			return "1. alpha\n2. beta", nil
+		case strings.Contains(prompt, "expand"):
+			return "expanded", nil
+		}
+		return "built", nil
+	}
+
+	exec := NewExecutor(store, claudeFn)
+	exec.SetPipeline("ralph", &PipelineDef{Steps: []PipelineStep{
+		{Label: "ideas", Kind: StepKindSimple, Method: "ideas"},
+		{Label: "expand", Kind: StepKindMap, MapMethod: "expand"},
+		{Label: "build", Kind: StepKindLoop, LoopMethod: "build"},
+	}})
+	methods := map[string]string{"ideas": "list ideas", "expand": "expand", "build": "build"}
+	if err := exec.Start("ralph", methods); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	var steps []StepResult
+	deadline := time.Now().Add(2 * time.Second)
+	for len(steps) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		steps = exec.Snapshot()["ralph"].SetupSteps
+	}
+	exec.StopAll(2 * time.Second)
+
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 setup step results, got %+v", steps)
+	}
+	if steps[0].Label != "ideas" || steps[0].Kind != StepKindSimple || steps[0].OutputPreview != "1. alpha\n2. beta" {
+		t.Errorf("unexpected simple step result: %+v", steps[0])
+	}
+	if steps[1].Label != "expand" || steps[1].Kind != StepKindMap || !strings.Contains(steps[1].OutputPreview, "expanded") {
+		t.Errorf("unexpected map step result: %+v", steps[1])
+	}
+	for _, sr := range steps {
+		if sr.Error != "" || !sr.FinishedAt.After(sr.StartedAt) {
+			t.Errorf("step %s should succeed with a positive duration: %+v", sr.Label, sr)
+		}
+	}
+
+	// A failing setup step records its error.
+	seedAgent(store, "broken")
+	failing := NewExecutor(store, fakeClaudeFailN(1, time.Millisecond))
+	failing.SetPipeline("broken", &PipelineDef{Steps: []PipelineStep{
+		{Label: "spec", Kind: StepKindSimple, Method: "spec"},
+		{Label: "build", Kind: StepKindLoop, LoopMethod: "build"},
+	}})
+	if err := failing.Start("broken", map[string]string{"spec": "s", "build": "b"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	var failed []StepResult
+	for len(failed) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		failed = failing.Snapshot()["broken"].SetupSteps
+	}
+	failing.StopAll(2 * time.Second)
+	if len(failed) != 1 || !strings.Contains(failed[0].Error, "simulated failure") {
+		t.Fatalf("expected failed setup step, got %+v", failed)
+	}
+}
+
 func TestPipelineValidation(t *testing.T) {
 	store := NewStore()
 	seedAgent(store, "invalid")
@@ -742,6 +926,77 @@ func TestPipelineLoopOnlyIsEquivalentToLegacy(t *testing.T) {
 	exec.StopAll(2 * time.Second)
 }
 
+// TestPipelineLoopWarmup verifies that a loop step's warmup method is used
+// for the first iteration only, with the loop body from iteration 2 on.
+func TestPipelineLoopWarmup(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "looper")
+
+	var mu sync.Mutex
+	var prompts []string
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		return "ok", nil
+	}
+
+	exec := NewExecutor(store, claudeFn)
+	exec.SetPipeline("looper", &PipelineDef{Steps: []PipelineStep{
+		{Label: "work", Kind: StepKindLoop, LoopMethod: "work", WarmupMethod: "setup"},
+	}})
+	methods := map[string]string{"work": "do the work", "setup": "clone the repo first"}
+	if err := exec.Start("looper", methods); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(prompts)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	exec.StopAll(2 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(prompts) < 2 {
+		t.Fatalf("expected at least 2 iterations, got %d", len(prompts))
+	}
+	if prompts[0] != "clone the repo first" {
+		t.Errorf("iteration 1 should use the warmup body, got %q", prompts[0])
+	}
+	if prompts[1] != "do the work" {
+		t.Errorf("iteration 2 should use the loop body, got %q", prompts[1])
+	}
+}
+
+func TestPipelineUnknownWarmupRejected(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "looper")
+
+	exec := NewExecutor(store, fakeClaude(time.Millisecond))
+	defer exec.StopAll(2 * time.Second)
+	exec.SetPipeline("looper", &PipelineDef{Steps: []PipelineStep{
+		{Label: "work", Kind: StepKindLoop, LoopMethod: "work", WarmupMethod: "setpu"},
+	}})
+	if err := exec.Start("looper", map[string]string{"work": "do the work", "setup": "clone the repo first"}); err == nil {
+		t.Fatal("a loop with an unknown warmup method should be rejected")
+	}
+	if obj := store.GetAgent("looper"); obj.State != RunStatePending {
+		t.Errorf("rejected agent should stay pending, got %s", obj.State)
+	}
+}
+
 // TestSplitItems verifies the item splitting heuristics used by map steps.
 func TestSplitItems(t *testing.T) {
 	// Numbered list
@@ -843,3 +1098,707 @@ func TestUpdateMethodBodyNonRunning(t *testing.T) {
 	// Should not panic or error — just a no-op.
 	exec.UpdateMethodBody("ghost", "work", "new body")
 }
+
+// TestExecutorUnhealthyAgents verifies that an agent whose every iteration
+// fails is reported once it has a full window of failures, and a healthy
+// agent is not.
+func TestExecutorUnhealthyAgents(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "broken")
+	seedAgent(store, "healthy")
+
+	failing := fakeClaudeFailN(1<<30, time.Millisecond)
+	ok := fakeClaude(time.Millisecond)
+	exec := NewExecutor(store, func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		if strings.Contains(prompt, "broken") {
+			return failing(ctx, prompt, onMessage)
+		}
+		return ok(ctx, prompt, onMessage)
+	})
+	exec.SetBackoff(Backoff{})
+	defer exec.StopAll(2 * time.Second)
+
+	if got := exec.UnhealthyAgents(3); len(got) != 0 {
+		t.Fatalf("expected no unhealthy agents before any iterations, got %v", got)
+	}
+	exec.Start("broken", map[string]string{"work": "broken work"})
+	exec.Start("healthy", map[string]string{"work": "healthy work"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	var got []string
+	for time.Now().Before(deadline) {
+		if got = exec.UnhealthyAgents(3); len(got) > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(got) != 1 || got[0] != "broken" {
+		t.Fatalf("expected only broken to be unhealthy, got %v", got)
+	}
+}
+
+// TestPipelineMapCancelled verifies that stopping an agent mid-map returns
+// promptly without recording the map step, an iteration, or a completion,
+// even when the cut-short items return output without an error.
+func TestPipelineMapCancelled(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "mapper")
+
+	mapStarted := make(chan struct{}, 3)
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		if strings.Contains(prompt, "list ideas") {
+			return "1. alpha\n2. beta\n3. gamma", nil
+		}
+		mapStarted <- struct{}{}
+		<-ctx.Done()
+		return "partial", nil
+	}
+
+	exec := NewExecutor(store, claudeFn)
+	var finished atomic.Bool
+	exec.OnFinish(func(string, error) { finished.Store(true) })
+	exec.SetPipeline("mapper", &PipelineDef{Steps: []PipelineStep{
+		{Label: "ideas", Kind: StepKindSimple, Method: "ideas"},
+		{Label: "expand", Kind: StepKindMap, MapMethod: "expand"},
+	}})
+	if err := exec.Start("mapper", map[string]string{"ideas": "list ideas", "expand": "expand"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case <-mapStarted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("map step never started")
+	}
+	runs := exec.runList()
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(runs))
+	}
+
+	start := time.Now()
+	if err := exec.Stop("mapper", 2*time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop mid-map took %v", elapsed)
+	}
+
+	if iters := runs[0].SnapshotIterations(); len(iters) != 0 {
+		t.Errorf("expected no iterations after a cancelled map, got %+v", iters)
+	}
+	if steps := runs[0].SnapshotSetupSteps(); len(steps) != 1 || steps[0].Label != "ideas" {
+		t.Errorf("expected only the ideas step recorded, got %+v", steps)
+	}
+	if finished.Load() {
+		t.Error("a cancelled pipeline should not report completion")
+	}
+}
+
+// TestExecutorSetDelay verifies that a delay set on a running agent spaces
+// out its iterations, and that clearing it resumes at full speed.
+func TestExecutorSetDelay(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "runner")
+
+	exec := NewExecutor(store, fakeClaude(time.Millisecond))
+	defer exec.StopAll(2 * time.Second)
+	if err := exec.SetDelay("runner", time.Second); err == nil {
+		t.Error("SetDelay on an agent that isn't running should fail")
+	}
+	exec.Start("runner", map[string]string{"work": "do work"})
+	run := exec.runList()[0]
+
+	if err := exec.SetDelay("runner", 80*time.Millisecond); err != nil {
+		t.Fatalf("SetDelay: %v", err)
+	}
+	if got := exec.Snapshot()["runner"].Delay; got != 80*time.Millisecond {
+		t.Errorf("snapshot delay = %v, want 80ms", got)
+	}
+	set := time.Now()
+	time.Sleep(300 * time.Millisecond)
+
+	var gaps int
+	var prev *IterationResult
+	for _, it := range run.SnapshotIterations() {
+		if it.StartedAt.Before(set) {
+			continue
+		}
+		if prev != nil {
+			gaps++
+			if gap := it.StartedAt.Sub(prev.FinishedAt); gap < 70*time.Millisecond {
+				t.Errorf("iterations %d and %d only %v apart with an 80ms delay", prev.Iteration, it.Iteration, gap)
+			}
+		}
+		it := it
+		prev = &it
+	}
+	if gaps == 0 {
+		t.Fatal("expected iterations to keep running with a delay set")
+	}
+
+	if err := exec.SetDelay("runner", 0); err != nil {
+		t.Fatalf("SetDelay: %v", err)
+	}
+	before := len(run.SnapshotIterations())
+	time.Sleep(50 * time.Millisecond)
+	if after := len(run.SnapshotIterations()); after-before < 5 {
+		t.Errorf("expected full-speed iterations after clearing the delay, got %d in 50ms", after-before)
+	}
+}
+
+// everyInterval is a Scheduler that triggers a fixed interval after t.
+type everyInterval time.Duration
+
+func (d everyInterval) Next(t time.Time) time.Time { return t.Add(time.Duration(d)) }
+
+func TestExecutorScheduledAgent(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "nightly")
+
+	var calls atomic.Int32
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		calls.Add(1)
+		return "report", nil
+	}
+	exec := NewExecutor(store, claudeFn)
+	defer exec.StopAll(2 * time.Second)
+	var finishes atomic.Int32
+	exec.OnFinish(func(string, error) { finishes.Add(1) })
+
+	looping := &PipelineDef{Steps: []PipelineStep{{Label: "loop(work)", Kind: StepKindLoop, LoopMethod: "work"}}}
+	exec.SetPipeline("nightly", looping)
+	exec.SetSchedule("nightly", everyInterval(100*time.Millisecond))
+	if err := exec.Start("nightly", map[string]string{"work": "work"}); err == nil {
+		t.Fatal("expected Start to reject a scheduled agent with a loop step")
+	}
+
+	exec.SetPipeline("nightly", &PipelineDef{Steps: []PipelineStep{{Label: "report", Kind: StepKindSimple, Method: "report"}}})
+	if err := exec.Start("nightly", map[string]string{"report": "write a report"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for store.GetAgent("nightly").State != RunStateScheduled && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := store.GetAgent("nightly").State; got != RunStateScheduled {
+		t.Errorf("state between runs = %q, want scheduled", got)
+	}
+	if next := exec.Snapshot()["nightly"].NextRun; next.IsZero() {
+		t.Error("snapshot should report the next run while scheduled")
+	}
+
+	for calls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := calls.Load(); n < 3 {
+		t.Fatalf("expected the pipeline to run on each trigger, got %d runs", n)
+	}
+	if finishes.Load() < 2 {
+		t.Errorf("expected a finish per run, got %d", finishes.Load())
+	}
+
+	if err := exec.Stop("nightly", 2*time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := store.GetAgent("nightly").State; got != RunStateStopped {
+		t.Errorf("state after Stop = %q, want stopped", got)
+	}
+}
+
+func TestExecutorMaxIterations(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "capped")
+
+	exec := NewExecutor(store, fakeClaude(time.Millisecond))
+	defer exec.StopAll(2 * time.Second)
+	var finished atomic.Bool
+	exec.OnFinish(func(string, error) { finished.Store(true) })
+	exec.SetMaxIterations("capped", 3)
+	if err := exec.Start("capped", map[string]string{"work": "do work"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	run := exec.runList()[0]
+
+	select {
+	case <-run.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not stop after reaching its cap")
+	}
+	if n := len(run.SnapshotIterations()); n != 3 {
+		t.Errorf("expected exactly 3 iterations, got %d", n)
+	}
+	if exec.IsRunning("capped") {
+		t.Error("agent should not be running after reaching its cap")
+	}
+	if got := store.GetAgent("capped").State; got != RunStateStopped {
+		t.Errorf("state = %q, want stopped", got)
+	}
+	if !finished.Load() {
+		t.Error("reaching the cap should report the pipeline as finished")
+	}
+}
+
+func TestPipelineLoopMaxIterations(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "capped")
+
+	exec := NewExecutor(store, fakeClaude(time.Millisecond))
+	defer exec.StopAll(2 * time.Second)
+	exec.SetPipeline("capped", &PipelineDef{Steps: []PipelineStep{
+		{Label: "spec", Kind: StepKindSimple, Method: "spec"},
+		{Label: "loop(build)", Kind: StepKindLoop, LoopMethod: "build", MaxIterations: 2},
+	}})
+	if err := exec.Start("capped", map[string]string{"spec": "write a spec", "build": "build"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	run := exec.runList()[0]
+
+	select {
+	case <-run.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not stop after reaching its loop step's cap")
+	}
+	if n := len(run.SnapshotIterations()); n != 2 {
+		t.Errorf("expected exactly 2 loop iterations, got %d", n)
+	}
+	if exec.IsRunning("capped") {
+		t.Error("agent should not be running after reaching its cap")
+	}
+}
+
+func TestBackoffAfter(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 30 * time.Second}
+	want := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for failures, w := range want {
+		if got := b.after(failures); got != w {
+			t.Errorf("after(%d) = %v, want %v", failures, got, w)
+		}
+	}
+	if got := (Backoff{}).after(3); got != 0 {
+		t.Errorf("zero backoff should not wait, got %v", got)
+	}
+}
+
+// TestExecutorBackoff verifies that the wait between failed iterations
+// grows, and that stopping the agent interrupts a backoff.
+func TestExecutorBackoff(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "flaky")
+
+	exec := NewExecutor(store, fakeClaudeFailN(1<<30, time.Millisecond))
+	exec.SetBackoff(Backoff{Initial: 20 * time.Millisecond, Max: time.Second})
+	if err := exec.Start("flaky", map[string]string{"work": "do work"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	run := exec.runList()[0]
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(run.SnapshotIterations()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	iters := run.SnapshotIterations()
+	if len(iters) < 4 {
+		t.Fatalf("expected at least 4 failed iterations, got %d", len(iters))
+	}
+	prevGap := time.Duration(0)
+	for i := 1; i < 4; i++ {
+		gap := iters[i].StartedAt.Sub(iters[i-1].FinishedAt)
+		if want := 20 * time.Millisecond << (i - 1); gap < want {
+			t.Errorf("gap before iteration %d = %v, want at least %v", iters[i].Iteration, gap, want)
+		}
+		if gap <= prevGap {
+			t.Errorf("gap before iteration %d = %v, not longer than the previous %v", iters[i].Iteration, gap, prevGap)
+		}
+		prevGap = gap
+	}
+
+	// The next wait is now at least 160ms; Stop must not sit through it.
+	start := time.Now()
+	if err := exec.Stop("flaky", 2*time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Stop during backoff took %v", elapsed)
+	}
+}
+
+func TestExecutorMaxConsecutiveFailures(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "broken")
+
+	exec := NewExecutor(store, fakeClaudeFailN(100, time.Millisecond))
+	defer exec.StopAll(2 * time.Second)
+	exec.SetBackoff(Backoff{})
+	exec.SetMaxConsecutiveFailures(3)
+	var finishErr atomic.Value
+	exec.OnFinish(func(_ string, err error) { finishErr.Store(err) })
+	if err := exec.Start("broken", map[string]string{"work": "do work"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	run := exec.runList()[0]
+
+	select {
+	case <-run.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not stop after 3 consecutive failures")
+	}
+	iters := run.SnapshotIterations()
+	if len(iters) != 3 {
+		t.Fatalf("expected exactly 3 failed iterations, got %d", len(iters))
+	}
+	for _, it := range iters {
+		if !strings.Contains(it.Error, "simulated failure") {
+			t.Errorf("iteration %d: expected a failure, got %q", it.Iteration, it.Error)
+		}
+	}
+	if !strings.Contains(iters[2].Error, "stopping after 3 consecutive failures") {
+		t.Errorf("last iteration should explain the stop, got %q", iters[2].Error)
+	}
+	if exec.IsRunning("broken") {
+		t.Error("agent should not be running after giving up")
+	}
+	if got := store.GetAgent("broken").State; got != RunStateStopped {
+		t.Errorf("state = %q, want stopped", got)
+	}
+	if err, _ := finishErr.Load().(error); err == nil {
+		t.Error("giving up should report the pipeline as failed")
+	}
+}
+
+// TestExecutorFailuresResetOnSuccess verifies that a success in between
+// keeps failures from adding up to the limit.
+func TestExecutorFailuresResetOnSuccess(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "flaky")
+
+	var calls atomic.Int64
+	exec := NewExecutor(store, func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		if calls.Add(1)%3 != 0 {
+			return "", fmt.Errorf("simulated failure")
+		}
+		return "ok", nil
+	})
+	defer exec.StopAll(2 * time.Second)
+	exec.SetBackoff(Backoff{})
+	exec.SetMaxConsecutiveFailures(3)
+	if err := exec.Start("flaky", map[string]string{"work": "do work"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() < 12 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !exec.IsRunning("flaky") {
+		t.Error("agent with a success every third iteration should keep running")
+	}
+}
+
+// TestExecutorScratchpad verifies that a value one agent writes with a
+// SCRATCH block reaches another agent's prompt.
+func TestExecutorUsage(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "builder")
+
+	// Three calls report usage, then the fourth blocks until the agent is
+	// stopped so the totals are stable while we look at them.
+	var calls atomic.Int32
+	exec := NewExecutorWithUsage(store, func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (ClaudeResult, error) {
+		if calls.Add(1) > 3 {
+			<-ctx.Done()
+			return ClaudeResult{}, ctx.Err()
+		}
+		return ClaudeResult{Output: "ok", Usage: Usage{InputTokens: 100, OutputTokens: 20, CostUSD: 0.5}}, nil
+	})
+	defer exec.StopAll(2 * time.Second)
+
+	if err := exec.Start("builder", map[string]string{"build": "do work"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	snap := exec.Snapshot()["builder"]
+	if len(snap.Iterations) != 3 {
+		t.Fatalf("expected 3 iterations, got %d", len(snap.Iterations))
+	}
+	for _, ir := range snap.Iterations {
+		if ir.InputTokens != 100 || ir.OutputTokens != 20 || ir.CostUSD != 0.5 {
+			t.Errorf("iteration %d usage = %+v, want the call's usage", ir.Iteration, ir.Usage)
+		}
+	}
+	if want := (Usage{InputTokens: 300, OutputTokens: 60, CostUSD: 1.5}); snap.Usage != want {
+		t.Errorf("run usage = %+v, want %+v", snap.Usage, want)
+	}
+}
+
+func TestExecutorScratchpad(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "writer")
+	seedAgent(store, "reader")
+
+	readerPrompts := make(chan string, 100)
+	exec := NewExecutor(store, func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		if strings.HasPrefix(prompt, "release") {
+			return "Released.\n```SCRATCH\nversion = 1.4.0\nnot a pair\n```\n", nil
+		}
+		select {
+		case readerPrompts <- prompt:
+		default:
+		}
+		time.Sleep(time.Millisecond)
+		return "ok", nil
+	})
+	defer exec.StopAll(2 * time.Second)
+
+	if err := exec.Start("reader", map[string]string{"work": "announce {scratch.version} {scratch.missing}"}); err != nil {
+		t.Fatalf("Start reader: %v", err)
+	}
+	if first := <-readerPrompts; first != "announce {scratch.version} {scratch.missing}" {
+		t.Errorf("unset keys should be left as-is, got %q", first)
+	}
+	if err := exec.Start("writer", map[string]string{"work": "release it"}); err != nil {
+		t.Fatalf("Start writer: %v", err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case p := <-readerPrompts:
+			if p == "announce 1.4.0 {scratch.missing}" {
+				if _, ok := store.GetScratch("not a pair"); ok {
+					t.Error("lines without = should be ignored")
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("reader never saw the value the writer set")
+		}
+	}
+}
+
+func TestPipelineMapScratchpad(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "mapper")
+	store.SetScratch("style", "terse")
+
+	var mu sync.Mutex
+	var itemPrompts []string
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		if strings.Contains(prompt, "list ideas") {
+			return "1. a\n2. b", nil
+		}
+		mu.Lock()
+		itemPrompts = append(itemPrompts, prompt)
+		mu.Unlock()
+		item := strings.SplitN(prompt, "\n", 2)[0]
+		return "done " + item + "\n```SCRATCH\nlast = " + item + "\n```", nil
+	}
+
+	exec := NewExecutor(store, claudeFn)
+	defer exec.StopAll(2 * time.Second)
+	finished := make(chan error, 1)
+	exec.OnFinish(func(_ string, err error) { finished <- err })
+	exec.SetPipeline("mapper", &PipelineDef{Steps: []PipelineStep{
+		{Label: "ideas", Kind: StepKindSimple, Method: "ideas"},
+		{Label: "expand", Kind: StepKindMap, MapMethod: "expand"},
+	}})
+	if err := exec.Start("mapper", map[string]string{"ideas": "list ideas", "expand": "expand, {scratch.style}"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline did not finish")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(itemPrompts) != 2 {
+		t.Fatalf("expected 2 map calls, got %q", itemPrompts)
+	}
+	for _, p := range itemPrompts {
+		if !strings.HasSuffix(p, "expand, terse") {
+			t.Errorf("map item prompt should read the scratchpad, got %q", p)
+		}
+	}
+	if v, _ := store.GetScratch("last"); v != "2. b" {
+		t.Errorf("scratch last = %q, want the last item's write", v)
+	}
+}
+
+func TestPipelineMapConcurrency(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "mapper")
+
+	var inFlight, peak, calls atomic.Int32
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		if strings.Contains(prompt, "list ideas") {
+			return "1. a\n2. b\n3. c\n4. d\n5. e\n6. f", nil
+		}
+		calls.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return "done", nil
+	}
+
+	exec := NewExecutor(store, claudeFn)
+	defer exec.StopAll(2 * time.Second)
+	finished := make(chan error, 1)
+	exec.OnFinish(func(_ string, err error) { finished <- err })
+	exec.SetPipeline("mapper", &PipelineDef{Steps: []PipelineStep{
+		{Label: "ideas", Kind: StepKindSimple, Method: "ideas"},
+		{Label: "expand", Kind: StepKindMap, MapMethod: "expand", MaxMapConcurrency: 2},
+	}})
+	if err := exec.Start("mapper", map[string]string{"ideas": "list ideas", "expand": "expand"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline did not finish")
+	}
+	if n := calls.Load(); n != 6 {
+		t.Errorf("expected 6 map calls, got %d", n)
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak concurrent map calls = %d, want at most 2", p)
+	}
+}
+
+func TestPipelineMapReduce(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "summariser")
+
+	var reduceCalls atomic.Int32
+	reports := make(chan string, 1)
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		switch {
+		case strings.Contains(prompt, "list ideas"):
+			return "1. a\n2. b\n3. c\n4. d", nil
+		case strings.HasSuffix(prompt, "\n\nexpand"):
+			item := strings.TrimSuffix(prompt, "\n\nexpand")
+			return strings.ToUpper(item[len(item)-1:]), nil
+		case strings.HasSuffix(prompt, "\n\nmerge"):
+			reduceCalls.Add(1)
+			pair := strings.Split(strings.TrimSuffix(prompt, "\n\nmerge"), mapSeparator)
+			if len(pair) != 2 {
+				return "", fmt.Errorf("reduce prompt should hold two items, got %q", prompt)
+			}
+			return pair[0] + "+" + pair[1], nil
+		default:
+			reports <- prompt
+			return "ok", nil
+		}
+	}
+
+	exec := NewExecutor(store, claudeFn)
+	defer exec.StopAll(2 * time.Second)
+	finished := make(chan error, 1)
+	exec.OnFinish(func(_ string, err error) { finished <- err })
+	exec.SetPipeline("summariser", &PipelineDef{Steps: []PipelineStep{
+		{Label: "ideas", Kind: StepKindSimple, Method: "ideas"},
+		{Label: "expand", Kind: StepKindMap, MapMethod: "expand"},
+		{Label: "merge", Kind: StepKindReduce, ReduceMethod: "merge"},
+		{Label: "report", Kind: StepKindSimple, Method: "report"},
+	}})
+	methods := map[string]string{"ideas": "list ideas", "expand": "expand", "merge": "merge", "report": "report"}
+	if err := exec.Start("summariser", methods); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline did not finish")
+	}
+	if n := reduceCalls.Load(); n != 3 {
+		t.Errorf("expected 3 reduce calls for 4 items, got %d", n)
+	}
+	if got := <-reports; got != "A+B+C+D\n\nreport" {
+		t.Errorf("report should get the single reduced output, got %q", got)
+	}
+
+	seedAgent(store, "orphan")
+	exec.SetPipeline("orphan", &PipelineDef{Steps: []PipelineStep{
+		{Label: "merge", Kind: StepKindReduce, ReduceMethod: "merge"},
+	}})
+	if err := exec.Start("orphan", methods); err == nil {
+		t.Error("a reduce with nothing before it should be rejected")
+	}
+}
+
+func TestSplitItemsKeepsRules(t *testing.T) {
+	items := splitItems("1. First item\n\n---\n\nstill the first item\n2. Second item")
+	if len(items) != 2 || !strings.Contains(items[0], "---\n\nstill the first item") {
+		t.Errorf("a markdown rule inside a list item should not split it, got %q", items)
+	}
+}
+
+func TestPipelineMapAfterMap(t *testing.T) {
+	store := NewStore()
+	seedAgent(store, "mapper")
+
+	var mu sync.Mutex
+	var reviewed []string
+	claudeFn := func(ctx context.Context, prompt string, onMessage func(ConvoMessage)) (string, error) {
+		switch {
+		case strings.Contains(prompt, "list ideas"):
+			return "1. a\n2. b", nil
+		case strings.HasSuffix(prompt, "\n\nexpand"):
+			// Results that contain the separator must stay whole.
+			return "draft" + mapSeparator + "notes", nil
+		}
+		mu.Lock()
+		reviewed = append(reviewed, strings.TrimSuffix(prompt, "\n\nreview"))
+		mu.Unlock()
+		return "ok", nil
+	}
+
+	exec := NewExecutor(store, claudeFn)
+	defer exec.StopAll(2 * time.Second)
+	finished := make(chan error, 1)
+	exec.OnFinish(func(_ string, err error) { finished <- err })
+	exec.SetPipeline("mapper", &PipelineDef{Steps: []PipelineStep{
+		{Label: "ideas", Kind: StepKindSimple, Method: "ideas"},
+		{Label: "expand", Kind: StepKindMap, MapMethod: "expand"},
+		{Label: "review", Kind: StepKindMap, MapMethod: "review"},
+	}})
+	if err := exec.Start("mapper", map[string]string{"ideas": "list ideas", "expand": "expand", "review": "review"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pipeline did not finish")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reviewed) != 2 || reviewed[0] != "draft"+mapSeparator+"notes" {
+		t.Errorf("the second map should get the 2 results whole, got %q", reviewed)
+	}
+}
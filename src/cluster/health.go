@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthHandler serves probe endpoints for orchestrators, for
+// `gcluster master --health-addr`:
+//
+//   - /healthz is 200 while the listener is up and the executor (if any)
+//     hasn't been shut down, and 503 otherwise.
+//   - /readyz is 200 once the master is accepting connections. The master
+//     loads persisted state before it starts listening, so ready also
+//     means state is loaded.
+//   - /unhealthy is a JSON array of agents whose last unhealthyWindow
+//     iterations all failed. It is informational and always 200: a stuck
+//     agent is not a reason to restart the master.
+//   - /metrics is per-agent state, iteration, failure and duration
+//     metrics in the Prometheus text format.
+func (s *Server) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		healthy := s.serving.Load()
+		if s.executor != nil && s.executor.rootCtx.Err() != nil {
+			healthy = false
+		}
+		probeResponse(w, healthy)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		probeResponse(w, s.serving.Load())
+	})
+	mux.HandleFunc("/unhealthy", func(w http.ResponseWriter, r *http.Request) {
+		names := []string{}
+		if s.executor != nil {
+			names = append(names, s.executor.UnhealthyAgents(s.unhealthyWindow)...)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(names)
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.writeMetrics(w)
+	})
+	return mux
+}
+
+func probeResponse(w http.ResponseWriter, ok bool) {
+	if !ok {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
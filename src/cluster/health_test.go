@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	srv, _, cleanup := startTestServerWithExecutor(t, fakeClaude(5*time.Millisecond))
+	defer cleanup()
+
+	probes := httptest.NewServer(srv.HealthHandler())
+	defer probes.Close()
+
+	status := func(path string) int {
+		t.Helper()
+		resp, err := http.Get(probes.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if code := status(path); code != http.StatusOK {
+			t.Errorf("%s on a started master = %d, want 200", path, code)
+		}
+	}
+
+	srv.Stop()
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if code := status(path); code != http.StatusServiceUnavailable {
+			t.Errorf("%s after Stop = %d, want 503", path, code)
+		}
+	}
+}
+
+func TestHealthBeforeListen(t *testing.T) {
+	srv := NewServer(NewStore(), "127.0.0.1:0")
+	rec := httptest.NewRecorder()
+	srv.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before ListenAndServe = %d, want 503", rec.Code)
+	}
+}
+
+func TestHealthUnhealthyAgents(t *testing.T) {
+	srv, _, cleanup := startTestServerWithExecutor(t, fakeClaudeFailN(1<<30, time.Millisecond))
+	defer cleanup()
+	srv.executor.SetBackoff(Backoff{})
+
+	probes := httptest.NewServer(srv.HealthHandler())
+	defer probes.Close()
+
+	unhealthy := func() []string {
+		t.Helper()
+		resp, err := http.Get(probes.URL + "/unhealthy")
+		if err != nil {
+			t.Fatalf("GET /unhealthy: %v", err)
+		}
+		defer resp.Body.Close()
+		var names []string
+		if err := json.NewDecoder(resp.Body).Decode(&names); err != nil {
+			t.Fatalf("decode /unhealthy: %v", err)
+		}
+		return names
+	}
+
+	if names := unhealthy(); len(names) != 0 {
+		t.Fatalf("expected no unhealthy agents before apply, got %v", names)
+	}
+	if _, err := srv.Apply(ApplyRequest{Agents: []AgentDef{{
+		Name:       "builder",
+		ID:         "abc",
+		Definition: `(defagent "builder" (pipeline (step "build" (loop build))))`,
+		Methods:    map[string]string{"build": "do some work"},
+	}}}, "test"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	var names []string
+	for time.Now().Before(deadline) {
+		if names = unhealthy(); len(names) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(names) != 1 || names[0] != "builder" {
+		t.Fatalf("expected builder to be unhealthy, got %v", names)
+	}
+}
+
+func TestHealthMetrics(t *testing.T) {
+	srv, _, cleanup := startTestServerWithExecutor(t, fakeClaude(time.Millisecond))
+	defer cleanup()
+	if _, err := srv.Apply(ApplyRequest{Agents: []AgentDef{{
+		Name:       "builder",
+		ID:         "abc",
+		Definition: `(defagent "builder" (pipeline (step "build" (loop build))))`,
+		Methods:    map[string]string{"build": "do some work"},
+	}}}, "test"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	probes := httptest.NewServer(srv.HealthHandler())
+	defer probes.Close()
+
+	metrics := func() string {
+		t.Helper()
+		resp, err := http.Get(probes.URL + "/metrics")
+		if err != nil {
+			t.Fatalf("GET /metrics: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	body := metrics()
+	for strings.Contains(body, `gcluster_agent_iterations_total{agent="builder"} 0`) ||
+		!strings.Contains(body, "gcluster_agent_iterations_total") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no iterations reported:\n%s", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+		body = metrics()
+	}
+
+	sample := regexp.MustCompile(`^[a-z_]+\{agent="[^"]*"(,state="[a-z]+")?\} [0-9.e+-]+$`)
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		if !sample.MatchString(line) {
+			t.Errorf("malformed metric line %q", line)
+		}
+	}
+	for _, want := range []string{
+		`gcluster_agent_state{agent="builder",state="running"} 1`,
+		`gcluster_agent_state{agent="builder",state="stopped"} 0`,
+		`gcluster_agent_failures_total{agent="builder"} 0`,
+		`gcluster_agent_iteration_duration_seconds_mean{agent="builder"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
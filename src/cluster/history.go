@@ -0,0 +1,60 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize bounds how many apply events the master remembers.
+const DefaultHistorySize = 100
+
+// ApplyEvent records one change to the cluster's definitions: which agents
+// an apply created or updated (or a delete removed), when, and from which
+// client address. Together they form a "who changed what when" audit trail.
+type ApplyEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Client    string    `json:"client"`
+	Created   []string  `json:"created,omitempty"`
+	Updated   []string  `json:"updated,omitempty"`
+	Deleted   []string  `json:"deleted,omitempty"`
+}
+
+// applyHistory is a fixed-size ring of ApplyEvents, oldest overwritten
+// first. It is in-memory only: the trail starts afresh when the master
+// restarts.
+type applyHistory struct {
+	mu     sync.Mutex
+	events []ApplyEvent
+	next   int
+	full   bool
+}
+
+func newApplyHistory(size int) *applyHistory {
+	if size < 1 {
+		size = 1
+	}
+	return &applyHistory{events: make([]ApplyEvent, size)}
+}
+
+// add records ev, evicting the oldest event if the ring is full.
+func (h *applyHistory) add(ev ApplyEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events[h.next] = ev
+	h.next = (h.next + 1) % len(h.events)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the recorded events, oldest first.
+func (h *applyHistory) list() []ApplyEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]ApplyEvent(nil), h.events[:h.next]...)
+	}
+	out := make([]ApplyEvent, 0, len(h.events))
+	out = append(out, h.events[h.next:]...)
+	return append(out, h.events[:h.next]...)
+}
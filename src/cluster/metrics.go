@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// writeMetrics writes per-agent metrics in the Prometheus text exposition
+// format, for the /metrics endpoint of HealthHandler. Iteration counts and
+// durations come from the executor's full run history, not the capped
+// snapshot sent to steer clients. Cancelled iterations (the agent was
+// stopped) are neither counted as failures nor timed.
+func (s *Server) writeMetrics(w io.Writer) {
+	objects := s.store.ListAgents()
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+
+	var runs []*AgentRun
+	if s.executor != nil {
+		runs = s.executor.runList()
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Name < runs[j].Name })
+
+	fmt.Fprintln(w, "# HELP gcluster_agent_state Whether the agent is in the given run state.")
+	fmt.Fprintln(w, "# TYPE gcluster_agent_state gauge")
+	for _, obj := range objects {
+		for _, st := range []RunState{RunStatePending, RunStateRunning, RunStateScheduled, RunStateStopped} {
+			v := 0
+			if obj.State == st {
+				v = 1
+			}
+			fmt.Fprintf(w, "gcluster_agent_state{agent=%s,state=%q} %d\n", metricLabel(obj.Name), st, v)
+		}
+	}
+
+	type agentStats struct {
+		name       string
+		iterations int
+		failures   int
+		mean       float64
+	}
+	stats := make([]agentStats, 0, len(runs))
+	for _, run := range runs {
+		st := agentStats{name: run.Name}
+		var total time.Duration
+		var timed int
+		for _, it := range run.SnapshotIterations() {
+			st.iterations++
+			if it.Error == "cancelled" {
+				continue
+			}
+			if it.Error != "" {
+				st.failures++
+			}
+			total += it.FinishedAt.Sub(it.StartedAt)
+			timed++
+		}
+		if timed > 0 {
+			st.mean = (total / time.Duration(timed)).Seconds()
+		}
+		stats = append(stats, st)
+	}
+
+	fmt.Fprintln(w, "# HELP gcluster_agent_iterations_total Iterations the agent has finished.")
+	fmt.Fprintln(w, "# TYPE gcluster_agent_iterations_total counter")
+	for _, st := range stats {
+		fmt.Fprintf(w, "gcluster_agent_iterations_total{agent=%s} %d\n", metricLabel(st.name), st.iterations)
+	}
+	fmt.Fprintln(w, "# HELP gcluster_agent_failures_total Iterations that ended in an error.")
+	fmt.Fprintln(w, "# TYPE gcluster_agent_failures_total counter")
+	for _, st := range stats {
+		fmt.Fprintf(w, "gcluster_agent_failures_total{agent=%s} %d\n", metricLabel(st.name), st.failures)
+	}
+	fmt.Fprintln(w, "# HELP gcluster_agent_iteration_duration_seconds_mean Mean iteration duration.")
+	fmt.Fprintln(w, "# TYPE gcluster_agent_iteration_duration_seconds_mean gauge")
+	for _, st := range stats {
+		fmt.Fprintf(w, "gcluster_agent_iteration_duration_seconds_mean{agent=%s} %g\n", metricLabel(st.name), st.mean)
+	}
+}
+
+// metricLabel quotes a label value, escaping as the exposition format
+// requires.
+func metricLabel(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
@@ -7,7 +7,11 @@
 // runs continue on their current revision until stopped.
 package cluster
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+)
 
 // RunState represents the lifecycle state of a cluster object.
 type RunState string
@@ -16,6 +20,8 @@ const (
 	RunStatePending RunState = "pending"
 	RunStateRunning RunState = "running"
 	RunStateStopped RunState = "stopped"
+	// RunStateScheduled is a scheduled agent idle between runs.
+	RunStateScheduled RunState = "scheduled"
 )
 
 // Revision captures a point-in-time snapshot of an agent definition.
@@ -44,6 +50,20 @@ type ClusterObject struct {
 	State RunState `json:"state"`
 	// CurrentRevision points to the active revision's ID.
 	CurrentRevision string `json:"current_revision"`
+	// Labels are free-form key/value tags (e.g. team=backend) for grouping
+	// and filtering. They are metadata, not part of the definition: changing
+	// them doesn't create a revision or restart the agent.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// FormatLabels renders labels as "k1=v1, k2=v2", sorted by key.
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
 }
 
 // AgentDef is the payload for an agent definition sent from apply to master.
@@ -67,6 +87,21 @@ type AgentDef struct {
 	// Populated at apply time by parsing the agent body. Nil for non-pipeline
 	// agents whose body is used directly as the prompt.
 	Pipeline *PipelineDef `json:"pipeline,omitempty"`
+	// OutputSink is an optional file path, relative to the master's working
+	// directory, that each successful iteration's output is appended to.
+	// Declared in the agent body with an `output: <path>` line.
+	OutputSink string `json:"output_sink,omitempty"`
+	// Labels are the agent's key/value tags, declared in the agent body
+	// with a `labels: team=backend, env=staging` line.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Schedule is an optional cron expression. A scheduled agent runs its
+	// loopless pipeline once per trigger instead of continuously.
+	// Declared in the agent body with a `schedule: 0 2 * * *` line.
+	Schedule string `json:"schedule,omitempty"`
+	// MaxIterations caps the iterations of an agent without a pipeline,
+	// whose body runs as a single loop. 0 means unlimited. Pipeline agents
+	// set the cap on their loop step instead.
+	MaxIterations int `json:"max_iterations,omitempty"`
 }
 
 // PipelineStepKind identifies how a pipeline step executes.
@@ -78,6 +113,7 @@ const (
 	StepKindSimple PipelineStepKind = "simple"
 	StepKindMap    PipelineStepKind = "map"
 	StepKindLoop   PipelineStepKind = "loop"
+	StepKindReduce PipelineStepKind = "reduce"
 )
 
 // PipelineStep describes a single step in an agent's pipeline.
@@ -85,16 +121,28 @@ const (
 type PipelineStep struct {
 	// Label is the output name for this step (e.g., "spec", "plan").
 	Label string `json:"label"`
-	// Kind is "simple", "map", or "loop".
+	// Kind is "simple", "map", "reduce", or "loop".
 	Kind PipelineStepKind `json:"kind"`
 	// Method is the method name for simple steps.
 	Method string `json:"method,omitempty"`
 	// LoopMethod is the method name for loop steps.
 	LoopMethod string `json:"loop_method,omitempty"`
+	// WarmupMethod, if set, is used instead of LoopMethod for the first
+	// iteration of a loop step.
+	WarmupMethod string `json:"warmup_method,omitempty"`
+	// MaxIterations caps how many iterations a loop step runs before the
+	// agent stops on its own. 0 means unlimited.
+	MaxIterations int `json:"max_iterations,omitempty"`
 	// MapMethod is the method name for map steps.
 	MapMethod string `json:"map_method,omitempty"`
 	// MapRef is the descriptive name of items for map steps.
 	MapRef string `json:"map_ref,omitempty"`
+	// MaxMapConcurrency bounds how many items of a map step run at once.
+	// 0 means unlimited.
+	MaxMapConcurrency int `json:"max_map_concurrency,omitempty"`
+	// ReduceMethod is the method name for reduce steps. It is called with
+	// two items at a time to fold the previous step's items into one.
+	ReduceMethod string `json:"reduce_method,omitempty"`
 }
 
 // PipelineDef describes the full pipeline structure for an agent.
@@ -107,9 +155,55 @@ type PipelineDef struct {
 	Steps        []PipelineStep `json:"steps"`
 }
 
+// SetLoopMaxIterations caps the pipeline's loop step at n iterations. It
+// reports false if the pipeline has no loop step.
+func (p *PipelineDef) SetLoopMaxIterations(n int) bool {
+	for i := range p.Steps {
+		if p.Steps[i].Kind == StepKindLoop {
+			p.Steps[i].MaxIterations = n
+			return true
+		}
+	}
+	return false
+}
+
+// SetMapConcurrency bounds every map step of the pipeline to n items at
+// once. It reports false if the pipeline has no map step.
+func (p *PipelineDef) SetMapConcurrency(n int) bool {
+	found := false
+	for i := range p.Steps {
+		if p.Steps[i].Kind == StepKindMap {
+			p.Steps[i].MaxMapConcurrency = n
+			found = true
+		}
+	}
+	return found
+}
+
+// loops reports whether the pipeline has a loop step.
+func (p *PipelineDef) loops() bool {
+	for _, st := range p.Steps {
+		if st.Kind == StepKindLoop {
+			return true
+		}
+	}
+	return false
+}
+
 // ApplySummary reports the outcome of an apply operation.
 type ApplySummary struct {
 	Created   []string `json:"created"`   // Names of newly created agents.
 	Updated   []string `json:"updated"`   // Names of agents with new revisions.
 	Unchanged []string `json:"unchanged"` // Names of agents whose definitions didn't change.
+
+	// Revisions maps each created or updated agent to its new revision ID
+	// (the applied AgentDef.ID, i.e. sexp.StableID of its definition).
+	Revisions map[string]string `json:"revisions,omitempty"`
+}
+
+func (s *ApplySummary) addRevision(name, id string) {
+	if s.Revisions == nil {
+		s.Revisions = make(map[string]string)
+	}
+	s.Revisions[name] = id
 }
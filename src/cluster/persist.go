@@ -2,10 +2,14 @@ package cluster
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
 // DefaultStateDir returns the default directory for cluster state files.
@@ -24,7 +28,8 @@ func DefaultStatePath() string {
 
 // persistedState is the on-disk JSON format for cluster state.
 type persistedState struct {
-	Objects []ClusterObject `json:"objects"`
+	Objects []ClusterObject   `json:"objects"`
+	Scratch map[string]string `json:"scratch,omitempty"`
 }
 
 // SaveState writes the current store contents to disk as JSON.
@@ -37,7 +42,7 @@ func SaveState(store *Store, path string) error {
 	}
 
 	objects := store.ListAgents()
-	state := persistedState{Objects: objects}
+	state := persistedState{Objects: objects, Scratch: store.Scratch()}
 
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
@@ -78,6 +83,7 @@ func LoadState(store *Store, path string) {
 	}
 
 	store.LoadState(state.Objects)
+	store.LoadScratch(state.Scratch)
 	log.Printf("loaded %d agents from %s", len(state.Objects), path)
 }
 
@@ -90,3 +96,87 @@ func preserveCorrupt(path string) {
 		log.Printf("preserved corrupt state file as %s", corrupt)
 	}
 }
+
+// ErrStateLocked is returned by LockState when another master holds the
+// lock on the state file.
+var ErrStateLocked = errors.New("another master is using this state file")
+
+// StateLock is an exclusive lock on a state file, held by a running master.
+// Two masters saving to the same path would silently clobber each other.
+type StateLock struct {
+	path string
+}
+
+// LockPath returns the lock file path for a state file.
+func LockPath(statePath string) string {
+	return statePath + ".lock"
+}
+
+// LockState acquires the lock for statePath by creating <state>.lock
+// exclusively and writing the current PID into it. If the lock is already
+// held, it returns an error wrapping ErrStateLocked that names the holder's
+// PID and whether that process is still alive. force removes an existing
+// lock first (for stale locks left behind by a crashed master).
+func LockState(statePath string, force bool) (*StateLock, error) {
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return nil, fmt.Errorf("create state dir: %w", err)
+	}
+
+	path := LockPath(statePath)
+	if force {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove lock file: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, lockHeldError(path)
+		}
+		return nil, fmt.Errorf("create lock file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%d\n", os.Getpid()); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("write lock file: %w", err)
+	}
+	return &StateLock{path: path}, nil
+}
+
+// Release removes the lock file. Safe to call on a nil lock.
+func (l *StateLock) Release() error {
+	if l == nil {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove lock file: %w", err)
+	}
+	return nil
+}
+
+// lockHeldError describes who holds an existing lock file.
+func lockHeldError(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%w (%s)", ErrStateLocked, path)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("%w (%s)", ErrStateLocked, path)
+	}
+	if !processAlive(pid) {
+		return fmt.Errorf("%w (%s, pid %d is not running — stale lock, use --force)", ErrStateLocked, path, pid)
+	}
+	return fmt.Errorf("%w (%s, pid %d)", ErrStateLocked, path, pid)
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
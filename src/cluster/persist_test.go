@@ -1,8 +1,11 @@
 package cluster
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -17,6 +20,7 @@ func TestSaveAndLoadState(t *testing.T) {
 		{Name: "beta", Definition: "(defagent \"beta\")", ID: "id-b"},
 	})
 	s1.SetRunState("alpha", RunStateRunning)
+	s1.SetScratch("release", "v1.2")
 
 	// Save.
 	if err := SaveState(s1, path); err != nil {
@@ -56,6 +60,10 @@ func TestSaveAndLoadState(t *testing.T) {
 	if beta.State != RunStatePending {
 		t.Fatalf("expected beta pending, got %s", beta.State)
 	}
+
+	if v, ok := s2.GetScratch("release"); !ok || v != "v1.2" {
+		t.Fatalf("expected scratch release=v1.2 after load, got %q (set %v)", v, ok)
+	}
 }
 
 func TestLoadStateMissingFile(t *testing.T) {
@@ -126,3 +134,52 @@ func TestSaveStateAtomicWrite(t *testing.T) {
 		t.Fatalf("temp file should not remain: %v", err)
 	}
 }
+
+func TestLockStateRefusesSecondMaster(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	lock, err := LockState(path, false)
+	if err != nil {
+		t.Fatalf("first LockState: %v", err)
+	}
+
+	if _, err := LockState(path, false); !errors.Is(err, ErrStateLocked) {
+		t.Fatalf("second LockState should fail with ErrStateLocked, got %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := os.Stat(LockPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("lock file should be removed on release")
+	}
+
+	lock2, err := LockState(path, false)
+	if err != nil {
+		t.Fatalf("LockState after release: %v", err)
+	}
+	lock2.Release()
+}
+
+func TestLockStateStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// A lock left by a process that no longer exists.
+	if err := os.WriteFile(LockPath(path), []byte(fmt.Sprintf("%d\n", 1<<30)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LockState(path, false)
+	if !errors.Is(err, ErrStateLocked) {
+		t.Fatalf("expected ErrStateLocked, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "stale") {
+		t.Errorf("error should report a stale lock, got %q", err)
+	}
+
+	lock, err := LockState(path, true)
+	if err != nil {
+		t.Fatalf("LockState with force: %v", err)
+	}
+	lock.Release()
+}
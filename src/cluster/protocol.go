@@ -1,6 +1,9 @@
 package cluster
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // DefaultAddr is the address the master listens on and clients connect to.
 const DefaultAddr = "127.0.0.1:43252"
@@ -9,13 +12,24 @@ const DefaultAddr = "127.0.0.1:43252"
 type MessageType string
 
 const (
-	MsgApplyRequest    MessageType = "apply_request"
-	MsgApplyResponse   MessageType = "apply_response"
-	MsgSteerSubscribe  MessageType = "steer_subscribe"
-	MsgSteerState      MessageType = "steer_state"
-	MsgSteerInject     MessageType = "steer_inject"
-	MsgSteerEditPrompt MessageType = "steer_edit_prompt"
-	MsgShutdownNotice  MessageType = "shutdown_notice"
+	MsgApplyRequest       MessageType = "apply_request"
+	MsgApplyResponse      MessageType = "apply_response"
+	MsgSteerSubscribe     MessageType = "steer_subscribe"
+	MsgSteerState         MessageType = "steer_state"
+	MsgSteerInject        MessageType = "steer_inject"
+	MsgSteerEditPrompt    MessageType = "steer_edit_prompt"
+	MsgSteerSkipIteration MessageType = "steer_skip_iteration"
+	MsgShutdownNotice     MessageType = "shutdown_notice"
+	MsgDescribeRequest    MessageType = "describe_request"
+	MsgDescribeResponse   MessageType = "describe_response"
+	MsgDeleteAgent        MessageType = "delete_agent"
+	MsgHistoryRequest     MessageType = "history_request"
+	MsgHistoryResponse    MessageType = "history_response"
+	MsgSteerReplay        MessageType = "steer_replay"
+	MsgSteerReplayResult  MessageType = "steer_replay_result"
+	MsgSteerSetInterval   MessageType = "steer_set_interval"
+	MsgConfigRequest      MessageType = "config_request"
+	MsgConfigResponse     MessageType = "config_response"
 )
 
 // Envelope wraps every protocol message. Clients and server exchange
@@ -30,6 +44,9 @@ type Envelope struct {
 // ApplyRequest is sent by `gcluster apply` to submit agent definitions.
 type ApplyRequest struct {
 	Agents []AgentDef `json:"agents"`
+	// NoStart stages the agents: new ones stay pending instead of being
+	// auto-started. A later apply without NoStart starts them.
+	NoStart bool `json:"no_start,omitempty"`
 }
 
 // ApplyResponse is the master's reply to an apply request.
@@ -39,7 +56,11 @@ type ApplyResponse struct {
 }
 
 // SteerSubscribeRequest is sent by `gcluster steer` to begin receiving state.
-type SteerSubscribeRequest struct{}
+// A ReadOnly subscriber only observes: the server refuses any inject, edit,
+// skip, delay change, delete or replay sent on its connection.
+type SteerSubscribeRequest struct {
+	ReadOnly bool `json:"read_only,omitempty"`
+}
 
 // SteerStatePayload pushes full cluster state to a steer client.
 // Objects contains the declarative state (definitions, revisions, run state).
@@ -49,11 +70,13 @@ type SteerSubscribeRequest struct{}
 // Pipelines contains pipeline structure per agent (agent name → PipelineDef).
 // Both are populated from the server's cache (set at apply time) so the TUI
 // can display human-readable method text and pipeline-aware tree structure.
+// Unhealthy names running agents whose recent iterations all failed.
 type SteerStatePayload struct {
 	Objects   []ClusterObject                `json:"objects"`
 	Runs      map[string]AgentRunSnapshot    `json:"runs,omitempty"`
 	Methods   map[string]map[string]string   `json:"methods,omitempty"`
 	Pipelines map[string]*PipelineDef        `json:"pipelines,omitempty"`
+	Unhealthy []string                       `json:"unhealthy,omitempty"`
 }
 
 // SteerInjectRequest sends a human message into an agent's conversation.
@@ -74,6 +97,79 @@ type SteerEditPromptRequest struct {
 	NewBody    string `json:"new_body"`
 }
 
+// SteerSkipIterationRequest asks the server to abort an agent's current
+// iteration and continue with the next one. Iteration guards against
+// skipping a newer iteration than the one the operator was looking at.
+type SteerSkipIterationRequest struct {
+	AgentName string `json:"agent_name"`
+	Iteration int    `json:"iteration"`
+}
+
+// SteerSetIntervalRequest sets the pause between an agent's loop
+// iterations, to throttle an agent without stopping it. Zero removes it.
+type SteerSetIntervalRequest struct {
+	AgentName string        `json:"agent_name"`
+	Delay     time.Duration `json:"delay"`
+}
+
+// SteerReplayRequest asks the master to re-run an agent's iteration with an
+// edited prompt, as a one-off experiment. The replay runs outside the
+// agent's loop and is not recorded in its iteration history; the result is
+// sent back to the requesting client only.
+type SteerReplayRequest struct {
+	AgentName      string `json:"agent_name"`
+	Iteration      int    `json:"iteration"`
+	OverridePrompt string `json:"override_prompt"`
+}
+
+// SteerReplayResult is the master's reply to a SteerReplayRequest.
+type SteerReplayResult struct {
+	AgentName string `json:"agent_name"`
+	Iteration int    `json:"iteration"`
+	Output    string `json:"output,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DeleteAgentRequest asks the server to stop an agent and remove it from
+// the cluster entirely. Unlike stop, the agent's definition and revision
+// history are discarded; reapplying creates it afresh.
+type DeleteAgentRequest struct {
+	AgentName string `json:"agent_name"`
+}
+
+// DescribeRequest asks the master for everything it knows about one agent.
+type DescribeRequest struct {
+	AgentName string `json:"agent_name"`
+}
+
+// DescribeResponse is the non-interactive counterpart of the steer TUI's
+// detail panes: the agent's cluster object (state and revisions), its
+// cached method bodies and pipeline, and its run snapshot if running.
+type DescribeResponse struct {
+	Object   *ClusterObject    `json:"object,omitempty"`
+	Methods  map[string]string `json:"methods,omitempty"`
+	Pipeline *PipelineDef      `json:"pipeline,omitempty"`
+	Run      *AgentRunSnapshot `json:"run,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// HistoryRequest asks the master for its recent apply events.
+type HistoryRequest struct{}
+
+// HistoryResponse lists the master's recent apply and delete events,
+// oldest first.
+type HistoryResponse struct {
+	Events []ApplyEvent `json:"events"`
+}
+
+// ConfigRequest asks the master for its effective configuration.
+type ConfigRequest struct{}
+
+// ConfigResponse carries the master's effective configuration.
+type ConfigResponse struct {
+	Config MasterConfig `json:"config"`
+}
+
 // ShutdownNoticePayload notifies clients the master is shutting down.
 type ShutdownNoticePayload struct {
 	Reason string `json:"reason"`
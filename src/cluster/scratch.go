@@ -0,0 +1,52 @@
+package cluster
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Agents share state through the store's scratchpad. A prompt reads a
+// value with a {scratch.<key>} placeholder, filled in when the prompt is
+// sent. An agent writes values by putting a fenced block in its output:
+//
+//	```SCRATCH
+//	status=ready
+//	```
+//
+// Each key=value line in the block is stored once the step or iteration
+// succeeds.
+
+var (
+	scratchRef   = regexp.MustCompile(`\{scratch\.([A-Za-z0-9_.-]+)\}`)
+	scratchBlock = regexp.MustCompile("(?ms)^```SCRATCH[ \t]*\n(.*?)^```")
+)
+
+// interpolateScratch replaces {scratch.<key>} placeholders in prompt with
+// the scratchpad's values. Unset keys are left as-is, like unbound method
+// parameters.
+func (e *Executor) interpolateScratch(prompt string) string {
+	return scratchRef.ReplaceAllStringFunc(prompt, func(ref string) string {
+		key := scratchRef.FindStringSubmatch(ref)[1]
+		if v, ok := e.store.GetScratch(key); ok {
+			return v
+		}
+		return ref
+	})
+}
+
+// writeScratch stores the key=value lines of any SCRATCH blocks in an
+// agent's output. Lines without a key are ignored.
+func (e *Executor) writeScratch(agentName, output string) {
+	for _, block := range scratchBlock.FindAllStringSubmatch(output, -1) {
+		for _, line := range strings.Split(block[1], "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				continue
+			}
+			e.store.SetScratch(key, strings.TrimSpace(value))
+			log.Printf("executor: agent %q set scratch %q", agentName, key)
+		}
+	}
+}
@@ -8,17 +8,19 @@
 //
 // Design: newline-delimited JSON over TCP. Each message is an Envelope with
 // a type field and a payload. The server reads one message at a time per
-// connection, allowing both request-response (apply) and streaming (steer)
+// connection, allowing both request-response (apply, describe, history) and streaming (steer)
 // patterns on the same protocol.
 package cluster
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,7 +30,7 @@ import (
 type Server struct {
 	store    *Store
 	executor *Executor
-	listener net.Listener
+	listener net.Listener // protected by mu
 	addr     string
 
 	// steer clients: connections that receive state push updates
@@ -46,39 +48,148 @@ type Server struct {
 	// can render pipeline-aware tree views.
 	agentPipelines map[string]*PipelineDef
 
+	// staged holds agents applied with NoStart. They stay pending until an
+	// apply without NoStart includes them again.
+	staged map[string]bool
+
+	// Iteration-driven pushes are coalesced so fast-iterating agents don't
+	// flood steer clients: at most one push per pushInterval, with a
+	// trailing push so the latest state is always delivered.
+	pushMu       sync.Mutex
+	pushInterval time.Duration
+	pushPending  bool
+	lastPush     time.Time
+
+	// limits bounds the size of definitions accepted by apply.
+	limits ApplyLimits
+
+	// webhook, if set, receives agent lifecycle events.
+	webhook atomic.Pointer[Webhook]
+
+	// history is the audit trail of applies and deletes.
+	history *applyHistory
+
+	// tlsConfig, if set, makes the listener serve TLS instead of plain TCP.
+	tlsConfig *tls.Config
+
+	// unhealthyWindow is how many consecutive failed iterations flag an
+	// agent as unhealthy to steer clients and the health endpoint.
+	unhealthyWindow int
+
+	// settings holds configuration the server reports but doesn't own
+	// (see SetMasterConfig). Protected by mu.
+	settings MasterConfig
+
+	// serving is true while the listener is accepting connections.
+	serving atomic.Bool
+
 	// done is closed when the server stops
 	done chan struct{}
 }
 
+// DefaultPushInterval is the minimum gap between iteration-driven state
+// pushes to steer clients. Store mutations are still pushed immediately.
+const DefaultPushInterval = 200 * time.Millisecond
+
+// ApplyLimits bounds what a single agent definition may contain, so a
+// buggy or hostile client can't bloat memory and persisted state through
+// the apply endpoint. A zero field means no limit.
+type ApplyLimits struct {
+	MaxDefinitionBytes int
+	MaxPipelineSteps   int
+	MaxMethods         int
+}
+
+// DefaultApplyLimits are generous for hand-written .p files.
+var DefaultApplyLimits = ApplyLimits{
+	MaxDefinitionBytes: 256 * 1024,
+	MaxPipelineSteps:   64,
+	MaxMethods:         64,
+}
+
+// check reports the first limit def exceeds.
+func (l ApplyLimits) check(def AgentDef) error {
+	if l.MaxDefinitionBytes > 0 && len(def.Definition) > l.MaxDefinitionBytes {
+		return fmt.Errorf("definition is %d bytes, limit is %d", len(def.Definition), l.MaxDefinitionBytes)
+	}
+	if l.MaxPipelineSteps > 0 && def.Pipeline != nil && len(def.Pipeline.Steps) > l.MaxPipelineSteps {
+		return fmt.Errorf("pipeline has %d steps, limit is %d", len(def.Pipeline.Steps), l.MaxPipelineSteps)
+	}
+	if l.MaxMethods > 0 && len(def.Methods) > l.MaxMethods {
+		return fmt.Errorf("agent has %d methods, limit is %d", len(def.Methods), l.MaxMethods)
+	}
+	return nil
+}
+
 // NewServer creates a server bound to the given store.
 // If claudeFn is non-nil, an executor is created to manage agent goroutines.
 // If claudeFn is nil, agents are stored but not executed (useful for tests
 // that don't need execution).
 // Call ListenAndServe to start accepting connections.
 func NewServer(store *Store, addr string, claudeFn ...ClaudeFunc) *Server {
+	if len(claudeFn) > 0 && claudeFn[0] != nil {
+		return NewServerWithUsage(store, addr, claudeFn[0].withUsage())
+	}
+	return NewServerWithUsage(store, addr, nil)
+}
+
+// NewServerWithUsage is NewServer for a claude function that reports token
+// usage and cost, so steer clients can show what each agent spends.
+func NewServerWithUsage(store *Store, addr string, claudeFn ClaudeUsageFunc) *Server {
 	if addr == "" {
 		addr = DefaultAddr
 	}
 	s := &Server{
-		store:          store,
-		addr:           addr,
-		steerClients:   make(map[net.Conn]bool),
-		agentMethods:   make(map[string]map[string]string),
-		agentPipelines: make(map[string]*PipelineDef),
-		done:           make(chan struct{}),
+		store:           store,
+		addr:            addr,
+		steerClients:    make(map[net.Conn]bool),
+		agentMethods:    make(map[string]map[string]string),
+		agentPipelines:  make(map[string]*PipelineDef),
+		staged:          make(map[string]bool),
+		pushInterval:    DefaultPushInterval,
+		limits:          DefaultApplyLimits,
+		history:         newApplyHistory(DefaultHistorySize),
+		unhealthyWindow: DefaultUnhealthyWindow,
+		done:            make(chan struct{}),
 	}
 
 	// Create executor if a claude function was provided.
-	if len(claudeFn) > 0 && claudeFn[0] != nil {
-		s.executor = NewExecutor(store, claudeFn[0])
+	if claudeFn != nil {
+		s.executor = NewExecutorWithUsage(store, claudeFn)
 		// Push state to steer clients after each iteration completes,
-		// so they see new iteration data in real time.
+		// so they see new iteration data in real time. Debounced, since
+		// this fires on every iteration and streaming update.
 		s.executor.OnIteration(func(agentName string) {
-			objects := store.ListAgents()
-			s.pushState(objects)
+			s.schedulePush()
+		})
+		s.executor.OnFinish(func(agentName string, err error) {
+			// Report the state the agent finished in: stopped if it
+			// stopped itself (iteration cap, repeated failures), else
+			// still running until it is stopped. A scheduled agent goes
+			// back to waiting for its next trigger, so each run is
+			// reported as a run rather than the agent completing.
+			ev := AgentEvent{Agent: agentName, Event: EventCompleted, OldState: RunStateRunning, NewState: RunStateRunning}
+			if obj := store.GetAgent(agentName); obj != nil {
+				ev.NewState = obj.State
+			}
+			if err != nil {
+				ev.Event = EventFailed
+				ev.Error = err.Error()
+			}
+			if s.executor.Scheduled(agentName) {
+				ev.NewState = RunStateScheduled
+				ev.Event = map[string]string{EventCompleted: EventRunCompleted, EventFailed: EventRunFailed}[ev.Event]
+			}
+			s.notifyWebhook(ev)
 		})
 	}
 
+	store.OnStateChange(func(name string, from, to RunState) {
+		if event := stateEvent(from, to); event != "" {
+			s.notifyWebhook(AgentEvent{Agent: name, Event: event, OldState: from, NewState: to})
+		}
+	})
+
 	// Wire up state change notifications to push to steer clients.
 	store.OnChange(func(objects []ClusterObject) {
 		s.pushState(objects)
@@ -87,6 +198,43 @@ func NewServer(store *Store, addr string, claudeFn ...ClaudeFunc) *Server {
 	return s
 }
 
+// SetApplyLimits replaces the limits enforced on apply requests.
+// Call before ListenAndServe.
+func (s *Server) SetApplyLimits(l ApplyLimits) {
+	s.limits = l
+}
+
+// SetWebhook registers a webhook to receive agent lifecycle events
+// (started, stopped, failed, completed, run_completed, run_failed). Pass nil
+// to disable. A previously set webhook is closed in the background.
+func (s *Server) SetWebhook(w *Webhook) {
+	if old := s.webhook.Swap(w); old != nil && old != w {
+		go old.Close(DefaultWebhookTimeout)
+	}
+}
+
+// SetUnhealthyWindow sets how many consecutive failed iterations flag an
+// agent as unhealthy. Call before ListenAndServe.
+func (s *Server) SetUnhealthyWindow(n int) {
+	s.unhealthyWindow = n
+}
+
+// SetTLSConfig makes the server accept TLS connections using conf.
+// Call before ListenAndServe.
+func (s *Server) SetTLSConfig(conf *tls.Config) {
+	s.tlsConfig = conf
+}
+
+// notifyWebhook stamps ev and hands it to the webhook, if one is set.
+func (s *Server) notifyWebhook(ev AgentEvent) {
+	w := s.webhook.Load()
+	if w == nil {
+		return
+	}
+	ev.Timestamp = time.Now()
+	w.Notify(ev)
+}
+
 // ListenAndServe starts the TCP listener and accepts connections.
 // It blocks until Stop is called or an unrecoverable error occurs.
 func (s *Server) ListenAndServe() error {
@@ -94,7 +242,13 @@ func (s *Server) ListenAndServe() error {
 	if err != nil {
 		return fmt.Errorf("listen on %s: %w", s.addr, err)
 	}
+	if s.tlsConfig != nil {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	s.mu.Lock()
 	s.listener = ln
+	s.mu.Unlock()
+	s.serving.Store(true)
 	log.Printf("gcluster master listening on %s", s.addr)
 
 	for {
@@ -119,8 +273,11 @@ func (s *Server) Executor() *Executor {
 
 // Addr returns the listener's address, useful in tests where port 0 is used.
 func (s *Server) Addr() string {
-	if s.listener != nil {
-		return s.listener.Addr().String()
+	s.mu.Lock()
+	ln := s.listener
+	s.mu.Unlock()
+	if ln != nil {
+		return ln.Addr().String()
 	}
 	return s.addr
 }
@@ -134,14 +291,22 @@ func (s *Server) Stop() {
 	default:
 	}
 	close(s.done)
+	s.serving.Store(false)
 
 	// Stop all running agents before closing connections.
 	if s.executor != nil {
 		s.executor.StopAll(10 * time.Second)
 	}
+	// Deliver the stop events just queued, then stop the webhook worker.
+	if w := s.webhook.Load(); w != nil {
+		w.Close(DefaultWebhookTimeout)
+	}
 
-	if s.listener != nil {
-		s.listener.Close()
+	s.mu.Lock()
+	ln := s.listener
+	s.mu.Unlock()
+	if ln != nil {
+		ln.Close()
 	}
 
 	// Notify and close steer clients
@@ -187,10 +352,16 @@ func (s *Server) handleConn(conn net.Conn) {
 		case MsgApplyRequest:
 			s.handleApply(conn, &env)
 		case MsgSteerSubscribe:
-			s.handleSteerSubscribe(conn)
+			s.handleSteerSubscribe(conn, &env, scanner)
 			return // steer connections stay open until disconnect
 		case MsgSteerInject:
 			s.handleSteerInject(&env)
+		case MsgDescribeRequest:
+			s.handleDescribe(conn, &env)
+		case MsgHistoryRequest:
+			s.sendResponse(conn, MsgHistoryResponse, HistoryResponse{Events: s.history.list()})
+		case MsgConfigRequest:
+			s.sendResponse(conn, MsgConfigResponse, ConfigResponse{Config: s.effectiveConfig()})
 		default:
 			log.Printf("unknown message type %q from %s", env.Type, conn.RemoteAddr())
 		}
@@ -198,8 +369,7 @@ func (s *Server) handleConn(conn net.Conn) {
 }
 
 // handleApply processes an apply_request: deserializes agent definitions,
-// applies them to the store, starts any pending agents, and sends back
-// the summary.
+// applies them, and sends back the summary.
 func (s *Server) handleApply(conn net.Conn, env *Envelope) {
 	var req ApplyRequest
 	if err := env.DecodePayload(&req); err != nil {
@@ -207,6 +377,42 @@ func (s *Server) handleApply(conn net.Conn, env *Envelope) {
 		return
 	}
 
+	summary, err := s.Apply(req, conn.RemoteAddr().String())
+	if err != nil {
+		s.sendResponse(conn, MsgApplyResponse, ApplyResponse{Error: err.Error()})
+		return
+	}
+	s.sendResponse(conn, MsgApplyResponse, ApplyResponse{Summary: summary})
+}
+
+// Apply validates req, applies its definitions to the store, records it in
+// the history as coming from client, and starts any pending agents. It
+// backs apply requests and `gcluster master --apply`.
+func (s *Server) Apply(req ApplyRequest, client string) (ApplySummary, error) {
+	// Reject the whole request if any definition is invalid, so a bad
+	// apply never leaves the cluster half-updated.
+	schedules := make(map[string]Scheduler)
+	for _, def := range req.Agents {
+		if err := s.limits.check(def); err != nil {
+			return ApplySummary{}, fmt.Errorf("agent %q: %v", def.Name, err)
+		}
+		if def.OutputSink != "" {
+			if err := ValidateOutputSink(def.OutputSink); err != nil {
+				return ApplySummary{}, fmt.Errorf("agent %q: %v", def.Name, err)
+			}
+		}
+		if def.Schedule != "" {
+			sched, err := ParseSchedule(def.Schedule)
+			if err != nil {
+				return ApplySummary{}, fmt.Errorf("agent %q: schedule: %v", def.Name, err)
+			}
+			if def.Pipeline == nil || def.Pipeline.loops() {
+				return ApplySummary{}, fmt.Errorf("agent %q: a scheduled agent's pipeline must not loop", def.Name)
+			}
+			schedules[def.Name] = sched
+		}
+	}
+
 	// Cache method bodies and pipeline definitions from the apply request
 	// for executor use when starting agents, and for steer clients to
 	// display human-readable method text and pipeline structure.
@@ -218,6 +424,11 @@ func (s *Server) handleApply(conn net.Conn, env *Envelope) {
 		if def.Pipeline != nil {
 			s.agentPipelines[def.Name] = def.Pipeline
 		}
+		if req.NoStart {
+			s.staged[def.Name] = true
+		} else {
+			delete(s.staged, def.Name)
+		}
 	}
 	s.mu.Unlock()
 
@@ -227,28 +438,82 @@ func (s *Server) handleApply(conn net.Conn, env *Envelope) {
 			if def.Pipeline != nil {
 				s.executor.SetPipeline(def.Name, def.Pipeline)
 			}
+			s.executor.SetOutputSink(def.Name, def.OutputSink)
+			s.executor.SetSchedule(def.Name, schedules[def.Name])
+			s.executor.SetMaxIterations(def.Name, def.MaxIterations)
 		}
 	}
 
 	summary := s.store.ApplyDefinitions(req.Agents)
-	s.sendResponse(conn, MsgApplyResponse, ApplyResponse{Summary: summary})
+	s.history.add(ApplyEvent{
+		Timestamp: time.Now(),
+		Client:    client,
+		Created:   summary.Created,
+		Updated:   summary.Updated,
+	})
 
 	// Start any newly-created (pending) agents if we have an executor.
+	// Staged agents are left pending.
 	if s.executor != nil {
 		s.mu.Lock()
 		methods := make(map[string]map[string]string, len(s.agentMethods))
 		for k, v := range s.agentMethods {
-			methods[k] = v
+			if !s.staged[k] {
+				methods[k] = v
+			}
 		}
 		s.mu.Unlock()
 		s.executor.StartPending(methods)
 	}
+	return summary, nil
+}
+
+// handleDescribe replies with a single agent's object, cached methods and
+// pipeline, and current run snapshot (if the agent is running).
+func (s *Server) handleDescribe(conn net.Conn, env *Envelope) {
+	var req DescribeRequest
+	if err := env.DecodePayload(&req); err != nil {
+		s.sendResponse(conn, MsgDescribeResponse, DescribeResponse{Error: fmt.Sprintf("decode error: %v", err)})
+		return
+	}
+
+	obj := s.store.GetAgent(req.AgentName)
+	if obj == nil {
+		s.sendResponse(conn, MsgDescribeResponse, DescribeResponse{Error: fmt.Sprintf("agent %q not found", req.AgentName)})
+		return
+	}
+
+	resp := DescribeResponse{Object: obj}
+	s.mu.Lock()
+	// Copy the methods: steer edits update the cached map in place, and
+	// the response is marshalled after the lock is released.
+	if methods, ok := s.agentMethods[req.AgentName]; ok {
+		resp.Methods = make(map[string]string, len(methods))
+		for k, v := range methods {
+			resp.Methods[k] = v
+		}
+	}
+	resp.Pipeline = s.agentPipelines[req.AgentName]
+	s.mu.Unlock()
+	if s.executor != nil {
+		if snap, ok := s.executor.Snapshot()[req.AgentName]; ok {
+			resp.Run = &snap
+		}
+	}
+	s.sendResponse(conn, MsgDescribeResponse, resp)
 }
 
 // handleSteerSubscribe registers a connection for state push updates.
 // It immediately sends the current state, then keeps the connection open
 // for future pushes. The connection stays open until the client disconnects.
-func (s *Server) handleSteerSubscribe(conn net.Conn) {
+// It keeps reading with the connection's existing scanner, so messages the
+// client sent right behind the subscribe aren't lost in its buffer.
+func (s *Server) handleSteerSubscribe(conn net.Conn, env *Envelope, scanner *bufio.Scanner) {
+	var sub SteerSubscribeRequest
+	if err := env.DecodePayload(&sub); err != nil {
+		log.Printf("steer_subscribe decode error: %v", err)
+	}
+
 	s.mu.Lock()
 	s.steerClients[conn] = true
 	s.mu.Unlock()
@@ -258,6 +523,7 @@ func (s *Server) handleSteerSubscribe(conn net.Conn) {
 	payload := SteerStatePayload{Objects: objects}
 	if s.executor != nil {
 		payload.Runs = s.executor.Snapshot()
+		payload.Unhealthy = s.executor.UnhealthyAgents(s.unhealthyWindow)
 	}
 	// Include cached methods and pipelines so TUI can display them.
 	s.mu.Lock()
@@ -277,8 +543,6 @@ func (s *Server) handleSteerSubscribe(conn net.Conn) {
 	s.sendResponse(conn, MsgSteerState, payload)
 
 	// Keep connection alive — read until EOF or error
-	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		if len(line) == 0 {
@@ -288,10 +552,26 @@ func (s *Server) handleSteerSubscribe(conn net.Conn) {
 		if err := json.Unmarshal(line, &env); err != nil {
 			continue
 		}
+		if sub.ReadOnly && isSteerMutation(env.Type) {
+			log.Printf("steer: refused %s from read-only client %s", env.Type, conn.RemoteAddr())
+			continue
+		}
 		if env.Type == MsgSteerInject {
 			s.handleSteerInject(&env)
 		} else if env.Type == MsgSteerEditPrompt {
 			s.handleSteerEditPrompt(&env)
+		} else if env.Type == MsgSteerSkipIteration {
+			s.handleSteerSkipIteration(&env)
+		} else if env.Type == MsgSteerSetInterval {
+			s.handleSteerSetInterval(&env)
+		} else if env.Type == MsgDeleteAgent {
+			s.handleDeleteAgent(conn, &env)
+		} else if env.Type == MsgSteerReplay {
+			s.handleSteerReplay(conn, &env)
+		} else if env.Type == MsgSteerSubscribe {
+			// Already subscribed: the connection gets every push once,
+			// so a repeat subscribe is a no-op.
+			log.Printf("steer subscribe: %s already subscribed, ignoring", conn.RemoteAddr())
 		}
 	}
 
@@ -301,6 +581,16 @@ func (s *Server) handleSteerSubscribe(conn net.Conn) {
 	s.mu.Unlock()
 }
 
+// isSteerMutation reports whether a steer message changes an agent or
+// spends a claude call, and so is refused from read-only subscribers.
+func isSteerMutation(t MessageType) bool {
+	switch t {
+	case MsgSteerInject, MsgSteerEditPrompt, MsgSteerSkipIteration, MsgSteerSetInterval, MsgDeleteAgent, MsgSteerReplay:
+		return true
+	}
+	return false
+}
+
 // handleSteerInject processes a steering message injection by forwarding it
 // to the executor. The executor queues the message on the agent's inject
 // channel; the agent goroutine drains injected messages before each iteration
@@ -356,13 +646,112 @@ func (s *Server) handleSteerEditPrompt(env *Envelope) {
 	s.pushState(objects)
 }
 
+// handleSteerSkipIteration aborts an agent's current iteration. The skipped
+// iteration shows up in the next iteration-driven state push.
+func (s *Server) handleSteerSkipIteration(env *Envelope) {
+	var req SteerSkipIterationRequest
+	if err := env.DecodePayload(&req); err != nil {
+		log.Printf("steer_skip_iteration decode error: %v", err)
+		return
+	}
+	log.Printf("steer skip_iteration: agent=%s iter=%d", req.AgentName, req.Iteration)
+
+	if s.executor == nil {
+		log.Printf("steer skip_iteration: no executor configured, request dropped")
+		return
+	}
+	if err := s.executor.SkipIteration(req.AgentName, req.Iteration); err != nil {
+		log.Printf("steer skip_iteration: %v", err)
+	}
+}
+
+// handleSteerSetInterval changes an agent's pause between iterations. The
+// new delay reaches steer clients in the next push.
+func (s *Server) handleSteerSetInterval(env *Envelope) {
+	var req SteerSetIntervalRequest
+	if err := env.DecodePayload(&req); err != nil {
+		log.Printf("steer_set_interval decode error: %v", err)
+		return
+	}
+	log.Printf("steer set_interval: agent=%s delay=%v", req.AgentName, req.Delay)
+
+	if s.executor == nil {
+		log.Printf("steer set_interval: no executor configured, request dropped")
+		return
+	}
+	if err := s.executor.SetDelay(req.AgentName, req.Delay); err != nil {
+		log.Printf("steer set_interval: %v", err)
+		return
+	}
+	s.schedulePush()
+}
+
+// handleSteerReplay runs a replay in the background and sends the result
+// to the requesting steer client only.
+func (s *Server) handleSteerReplay(conn net.Conn, env *Envelope) {
+	var req SteerReplayRequest
+	if err := env.DecodePayload(&req); err != nil {
+		log.Printf("steer_replay decode error: %v", err)
+		return
+	}
+	log.Printf("steer replay: agent=%s iter=%d (%d bytes)", req.AgentName, req.Iteration, len(req.OverridePrompt))
+
+	go func() {
+		result := SteerReplayResult{AgentName: req.AgentName, Iteration: req.Iteration}
+		if s.executor == nil {
+			result.Error = "no executor configured"
+		} else if out, err := s.executor.Replay(req.AgentName, req.Iteration, req.OverridePrompt); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Output = out
+		}
+		// Serialize with state pushes, which write to the same connection.
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.sendResponse(conn, MsgSteerReplayResult, result)
+	}()
+}
+
+// handleDeleteAgent stops an agent and removes it from the store and the
+// server's caches. The store mutation pushes the updated state to all steer
+// clients.
+func (s *Server) handleDeleteAgent(conn net.Conn, env *Envelope) {
+	var req DeleteAgentRequest
+	if err := env.DecodePayload(&req); err != nil {
+		log.Printf("delete_agent decode error: %v", err)
+		return
+	}
+	log.Printf("delete agent: %s", req.AgentName)
+
+	if s.executor != nil {
+		s.executor.Remove(req.AgentName, 10*time.Second)
+	}
+
+	s.mu.Lock()
+	delete(s.agentMethods, req.AgentName)
+	delete(s.agentPipelines, req.AgentName)
+	delete(s.staged, req.AgentName)
+	s.mu.Unlock()
+
+	if !s.store.DeleteAgent(req.AgentName) {
+		log.Printf("delete agent: %q not found", req.AgentName)
+		return
+	}
+	s.history.add(ApplyEvent{
+		Timestamp: time.Now(),
+		Client:    conn.RemoteAddr().String(),
+		Deleted:   []string{req.AgentName},
+	})
+}
+
 // pushState sends the current cluster state to all subscribed steer clients.
-// Called by the store's OnChange callback after every mutation, and by the
-// executor's OnIteration callback after each iteration completes.
+// Called by the store's OnChange callback after every mutation, and (via
+// schedulePush) by the executor's OnIteration callback after each iteration.
 func (s *Server) pushState(objects []ClusterObject) {
 	payload := SteerStatePayload{Objects: objects}
 	if s.executor != nil {
 		payload.Runs = s.executor.Snapshot()
+		payload.Unhealthy = s.executor.UnhealthyAgents(s.unhealthyWindow)
 	}
 
 	// Grab cached methods and pipelines under s.mu so TUI can display them.
@@ -405,6 +794,35 @@ func (s *Server) pushState(objects []ClusterObject) {
 	}
 }
 
+// schedulePush coalesces iteration-driven state pushes. If no push happened
+// within the last pushInterval it pushes immediately; otherwise it schedules
+// a single trailing push for the end of the window. Calls made while a
+// trailing push is pending are absorbed by it.
+func (s *Server) schedulePush() {
+	s.pushMu.Lock()
+	if s.pushPending {
+		s.pushMu.Unlock()
+		return
+	}
+	wait := s.pushInterval - time.Since(s.lastPush)
+	if wait <= 0 {
+		s.lastPush = time.Now()
+		s.pushMu.Unlock()
+		s.pushState(s.store.ListAgents())
+		return
+	}
+	s.pushPending = true
+	s.pushMu.Unlock()
+
+	time.AfterFunc(wait, func() {
+		s.pushMu.Lock()
+		s.pushPending = false
+		s.lastPush = time.Now()
+		s.pushMu.Unlock()
+		s.pushState(s.store.ListAgents())
+	})
+}
+
 // sendResponse marshals and sends a single envelope to a connection.
 func (s *Server) sendResponse(conn net.Conn, msgType MessageType, payload interface{}) {
 	env, err := NewEnvelope(msgType, payload)
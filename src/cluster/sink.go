@@ -0,0 +1,40 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ValidateOutputSink checks that an agent's output sink path stays inside
+// the master's working directory: it must be relative and must not climb
+// out with "..". Agents are declared in .p files that may come from
+// anywhere, so they must not be able to append to arbitrary files.
+func ValidateOutputSink(path string) error {
+	if !filepath.IsLocal(path) {
+		return fmt.Errorf("output sink %q must be a relative path inside the working directory", path)
+	}
+	return nil
+}
+
+// appendOutput appends one iteration's output to the sink file under a
+// timestamped header, creating the file and its directory if needed.
+func appendOutput(path string, iteration int, output string) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create sink dir: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entry := fmt.Sprintf("=== %s iteration %d ===\n%s\n\n", time.Now().Format(time.RFC3339), iteration, output)
+	if _, err := f.WriteString(entry); err != nil {
+		return err
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ package cluster
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -31,8 +32,13 @@ import (
 type SteerClient struct {
 	conn    net.Conn
 	addr    string
+	tls     *tls.Config
 	scanner *bufio.Scanner
 
+	// readOnly clients subscribe as observers and refuse to send
+	// mutations; the server refuses them too.
+	readOnly bool
+
 	// StateCh delivers state payloads from the master. The TUI reads
 	// from this channel to update its view. Buffered to avoid blocking
 	// the read goroutine if the TUI is slow to consume.
@@ -46,6 +52,9 @@ type SteerClient struct {
 	// this to clear the error banner and re-subscribe for state updates.
 	ReconnectCh chan struct{}
 
+	// ReplayCh delivers the results of Replay requests.
+	ReplayCh chan SteerReplayResult
+
 	mu     sync.Mutex
 	closed bool
 	done   chan struct{}
@@ -54,7 +63,24 @@ type SteerClient struct {
 // NewSteerClient creates a client that connects to the master at the given address.
 // It subscribes for state updates and starts reading in the background.
 func NewSteerClient(addr string) (*SteerClient, error) {
-	conn, err := net.Dial("tcp", addr)
+	return NewSteerClientTLS(addr, nil)
+}
+
+// NewSteerClientTLS is NewSteerClient over TLS when tlsConf is non-nil.
+// Reconnects use the same config.
+func NewSteerClientTLS(addr string, tlsConf *tls.Config) (*SteerClient, error) {
+	return newSteerClient(addr, tlsConf, false)
+}
+
+// NewReadOnlySteerClient is NewSteerClientTLS for an observer: it subscribes
+// read-only, and its Inject, EditPrompt, SkipIteration, SetInterval, Replay
+// and DeleteAgent return an error without sending anything.
+func NewReadOnlySteerClient(addr string, tlsConf *tls.Config) (*SteerClient, error) {
+	return newSteerClient(addr, tlsConf, true)
+}
+
+func newSteerClient(addr string, tlsConf *tls.Config, readOnly bool) (*SteerClient, error) {
+	conn, err := Dial(addr, tlsConf)
 	if err != nil {
 		return nil, fmt.Errorf("cannot connect to master at %s — is `gcluster master` running?\n%w", addr, err)
 	}
@@ -62,16 +88,19 @@ func NewSteerClient(addr string) (*SteerClient, error) {
 	sc := &SteerClient{
 		conn:        conn,
 		addr:        addr,
+		tls:         tlsConf,
+		readOnly:    readOnly,
 		scanner:     bufio.NewScanner(conn),
 		StateCh:     make(chan SteerStatePayload, 16),
 		ErrCh:       make(chan error, 4),
 		ReconnectCh: make(chan struct{}, 1),
+		ReplayCh:    make(chan SteerReplayResult, 4),
 		done:        make(chan struct{}),
 	}
 	sc.scanner.Buffer(make([]byte, 0, 4*1024*1024), 4*1024*1024)
 
 	// Send subscribe message
-	env, err := NewEnvelope(MsgSteerSubscribe, SteerSubscribeRequest{})
+	env, err := NewEnvelope(MsgSteerSubscribe, SteerSubscribeRequest{ReadOnly: sc.readOnly})
 	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("marshal subscribe: %w", err)
@@ -162,6 +191,18 @@ func (sc *SteerClient) readMessages() error {
 				sc.StateCh <- payload
 			}
 
+		case MsgSteerReplayResult:
+			var result SteerReplayResult
+			if err := env.DecodePayload(&result); err != nil {
+				log.Printf("steer client: decode replay result: %v", err)
+				continue
+			}
+			select {
+			case sc.ReplayCh <- result:
+			default:
+				log.Printf("steer client: replay result for %q dropped, nobody reading", result.AgentName)
+			}
+
 		case MsgShutdownNotice:
 			var payload ShutdownNoticePayload
 			env.DecodePayload(&payload)
@@ -205,7 +246,7 @@ func (sc *SteerClient) reconnect() bool {
 		}
 		sc.mu.Unlock()
 
-		conn, err := net.Dial("tcp", sc.addr)
+		conn, err := Dial(sc.addr, sc.tls)
 		if err != nil {
 			log.Printf("steer client: reconnect attempt %d failed: %v", attempt, err)
 			backoff *= 2
@@ -216,7 +257,7 @@ func (sc *SteerClient) reconnect() bool {
 		}
 
 		// Re-subscribe
-		env, err := NewEnvelope(MsgSteerSubscribe, SteerSubscribeRequest{})
+		env, err := NewEnvelope(MsgSteerSubscribe, SteerSubscribeRequest{ReadOnly: sc.readOnly})
 		if err != nil {
 			conn.Close()
 			continue
@@ -266,6 +307,9 @@ func (sc *SteerClient) Inject(agentName, stepLabel string, iteration int, messag
 	if sc.closed {
 		return fmt.Errorf("client closed")
 	}
+	if sc.readOnly {
+		return fmt.Errorf("read-only client")
+	}
 
 	req := SteerInjectRequest{
 		AgentName: agentName,
@@ -299,6 +343,9 @@ func (sc *SteerClient) EditPrompt(agentName, methodName, newBody string) error {
 	if sc.closed {
 		return fmt.Errorf("client closed")
 	}
+	if sc.readOnly {
+		return fmt.Errorf("read-only client")
+	}
 
 	req := SteerEditPromptRequest{
 		AgentName:  agentName,
@@ -320,6 +367,124 @@ func (sc *SteerClient) EditPrompt(agentName, methodName, newBody string) error {
 	return nil
 }
 
+// SkipIteration asks the master to abort the agent's in-flight iteration
+// and continue its loop with the next one.
+func (sc *SteerClient) SkipIteration(agentName string, iteration int) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return fmt.Errorf("client closed")
+	}
+	if sc.readOnly {
+		return fmt.Errorf("read-only client")
+	}
+
+	env, err := NewEnvelope(MsgSteerSkipIteration, SteerSkipIterationRequest{AgentName: agentName, Iteration: iteration})
+	if err != nil {
+		return fmt.Errorf("marshal skip_iteration: %w", err)
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal skip_iteration: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err := sc.conn.Write(data); err != nil {
+		return fmt.Errorf("send skip_iteration: %w", err)
+	}
+	return nil
+}
+
+// SetInterval asks the master to pause delay between the agent's loop
+// iterations. Zero removes the pause.
+func (sc *SteerClient) SetInterval(agentName string, delay time.Duration) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return fmt.Errorf("client closed")
+	}
+	if sc.readOnly {
+		return fmt.Errorf("read-only client")
+	}
+
+	env, err := NewEnvelope(MsgSteerSetInterval, SteerSetIntervalRequest{AgentName: agentName, Delay: delay})
+	if err != nil {
+		return fmt.Errorf("marshal steer_set_interval: %w", err)
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal steer_set_interval: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err := sc.conn.Write(data); err != nil {
+		return fmt.Errorf("send steer_set_interval: %w", err)
+	}
+	return nil
+}
+
+// Replay asks the master to re-run the agent's iteration with prompt in
+// place of the original, without touching the live loop. The result
+// arrives on ReplayCh.
+func (sc *SteerClient) Replay(agentName string, iteration int, prompt string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return fmt.Errorf("client closed")
+	}
+	if sc.readOnly {
+		return fmt.Errorf("read-only client")
+	}
+
+	env, err := NewEnvelope(MsgSteerReplay, SteerReplayRequest{AgentName: agentName, Iteration: iteration, OverridePrompt: prompt})
+	if err != nil {
+		return fmt.Errorf("marshal steer_replay: %w", err)
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal steer_replay: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err := sc.conn.Write(data); err != nil {
+		return fmt.Errorf("send steer_replay: %w", err)
+	}
+	return nil
+}
+
+// DeleteAgent asks the master to stop the named agent and remove it from
+// the cluster. The removal arrives as a normal state push.
+func (sc *SteerClient) DeleteAgent(name string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return fmt.Errorf("client closed")
+	}
+	if sc.readOnly {
+		return fmt.Errorf("read-only client")
+	}
+
+	env, err := NewEnvelope(MsgDeleteAgent, DeleteAgentRequest{AgentName: name})
+	if err != nil {
+		return fmt.Errorf("marshal delete_agent: %w", err)
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal delete_agent: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err := sc.conn.Write(data); err != nil {
+		return fmt.Errorf("send delete_agent: %w", err)
+	}
+	return nil
+}
+
+// ReadOnly reports whether the client was opened as an observer.
+func (sc *SteerClient) ReadOnly() bool {
+	return sc.readOnly
+}
+
 // Close disconnects from the master and stops the reconnect loop.
 // It is safe to call multiple times.
 func (sc *SteerClient) Close() error {
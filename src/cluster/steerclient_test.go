@@ -176,7 +176,7 @@ func TestSteerClientReconnect(t *testing.T) {
 
 	// Wait for listener
 	deadline := time.Now().Add(2 * time.Second)
-	for srv2.listener == nil && time.Now().Before(deadline) {
+	for !srv2.serving.Load() && time.Now().Before(deadline) {
 		time.Sleep(5 * time.Millisecond)
 	}
 
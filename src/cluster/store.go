@@ -9,23 +9,66 @@ import (
 // single source of truth for cluster state. All mutations go through Store
 // methods which hold a write lock, ensuring consistency.
 //
-// The store is additive-only: agents are never deleted, only updated with
-// new revisions or state changes. This matches the spec's declarative model
-// where `gcluster apply` only adds or updates, never removes.
+// Apply is additive-only: it never deletes agents, only adds them or updates
+// them with new revisions and state changes. Agents are removed only by an
+// explicit DeleteAgent (the steer TUI's delete action).
 type Store struct {
 	mu      sync.RWMutex
 	objects map[string]*ClusterObject // keyed by agent name
+	scratch map[string]string         // cluster-wide scratchpad shared by agents
 
 	// onChange is called (if non-nil) after every state mutation.
 	// The callback receives a snapshot of all objects. Implementations
 	// must not block — long work should be dispatched to a goroutine.
 	onChange func([]ClusterObject)
+
+	// onStateChange is called (if non-nil) when SetRunState actually
+	// changes an agent's run state. Same non-blocking contract as onChange.
+	onStateChange func(name string, from, to RunState)
 }
 
 // NewStore creates an empty cluster state store.
 func NewStore() *Store {
 	return &Store{
 		objects: make(map[string]*ClusterObject),
+		scratch: make(map[string]string),
+	}
+}
+
+// SetScratch sets a scratchpad key, shared by every agent in the cluster.
+func (s *Store) SetScratch(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scratch[key] = value
+}
+
+// GetScratch returns a scratchpad value and whether the key is set.
+func (s *Store) GetScratch(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.scratch[key]
+	return v, ok
+}
+
+// Scratch returns a copy of the whole scratchpad, for persistence.
+func (s *Store) Scratch() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cp := make(map[string]string, len(s.scratch))
+	for k, v := range s.scratch {
+		cp[k] = v
+	}
+	return cp
+}
+
+// LoadScratch replaces the scratchpad. Used for loading persisted state
+// on startup.
+func (s *Store) LoadScratch(scratch map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scratch = make(map[string]string, len(scratch))
+	for k, v := range scratch {
+		s.scratch[k] = v
 	}
 }
 
@@ -36,6 +79,15 @@ func (s *Store) OnChange(fn func([]ClusterObject)) {
 	s.onChange = fn
 }
 
+// OnStateChange registers a callback invoked when an agent's run state
+// transitions (e.g. pending → running). Unlike OnChange it carries the
+// agent name and both states, so listeners need not diff snapshots.
+func (s *Store) OnStateChange(fn func(name string, from, to RunState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onStateChange = fn
+}
+
 // notifyLocked calls the onChange callback with a snapshot.
 // Caller must hold at least a read lock.
 func (s *Store) notifyLocked() {
@@ -80,11 +132,17 @@ func (s *Store) ApplyDefinitions(defs []AgentDef) ApplySummary {
 				Revisions:       []Revision{rev},
 				State:           RunStatePending,
 				CurrentRevision: def.ID,
+				Labels:          def.Labels,
 			}
 			summary.Created = append(summary.Created, def.Name)
+			summary.addRevision(def.Name, def.ID)
 			continue
 		}
 
+		// Labels are metadata: always take the applied set, even when the
+		// definition itself is unchanged.
+		existing.Labels = def.Labels
+
 		// Existing agent — check if definition changed.
 		if existing.ID == def.ID {
 			summary.Unchanged = append(summary.Unchanged, def.Name)
@@ -104,6 +162,7 @@ func (s *Store) ApplyDefinitions(defs []AgentDef) ApplySummary {
 		existing.Revisions = append(existing.Revisions, rev)
 		existing.CurrentRevision = def.ID
 		summary.Updated = append(summary.Updated, def.Name)
+		summary.addRevision(def.Name, def.ID)
 	}
 
 	s.notifyLocked()
@@ -155,8 +214,26 @@ func (s *Store) SetRunState(name string, state RunState) bool {
 	if !ok {
 		return false
 	}
+	from := obj.State
 	obj.State = state
 	s.notifyLocked()
+	if from != state && s.onStateChange != nil {
+		s.onStateChange(name, from, state)
+	}
+	return true
+}
+
+// DeleteAgent removes the named agent and its revision history.
+// Returns false if the agent doesn't exist.
+func (s *Store) DeleteAgent(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.objects[name]; !ok {
+		return false
+	}
+	delete(s.objects, name)
+	s.notifyLocked()
 	return true
 }
 
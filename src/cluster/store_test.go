@@ -259,3 +259,18 @@ func TestLoadState(t *testing.T) {
 		t.Fatalf("expected pending state after load, got %s", agent.State)
 	}
 }
+
+func TestDeleteAgent(t *testing.T) {
+	s := NewStore()
+	s.ApplyDefinitions([]AgentDef{{Name: "alpha", Definition: "(defagent \"alpha\")", ID: "id-a"}})
+
+	if !s.DeleteAgent("alpha") {
+		t.Fatal("DeleteAgent should report success for an existing agent")
+	}
+	if s.GetAgent("alpha") != nil {
+		t.Fatal("agent should be gone after delete")
+	}
+	if s.DeleteAgent("alpha") {
+		t.Fatal("DeleteAgent should report false for a missing agent")
+	}
+}
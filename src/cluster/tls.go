@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+)
+
+// Plain TCP is the default transport. TLS is opt-in for masters reachable
+// beyond localhost: the master serves with a certificate and key, and
+// clients dial with a config that trusts it.
+
+// ServerTLSConfig loads a certificate and key for the master's listener.
+func ServerTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// ClientTLSConfig returns a client config that verifies the master against
+// the PEM certificates in caFile, or the system roots if caFile is empty.
+// Pass a self-signed master certificate as caFile to trust it.
+func ClientTLSConfig(caFile string) (*tls.Config, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return conf, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read TLS CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	conf.RootCAs = pool
+	return conf, nil
+}
+
+// Dial connects to the master at addr, over TLS if conf is non-nil.
+func Dial(addr string, conf *tls.Config) (net.Conn, error) {
+	if conf == nil {
+		return net.Dial("tcp", addr)
+	}
+	return tls.Dial("tcp", addr, conf)
+}
@@ -0,0 +1,124 @@
+package cluster
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1 and
+// its key to a temp dir, returning both paths.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gcluster test"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// TestServerApplyOverTLS verifies that an apply and a steer subscription
+// round-trip over TLS with a self-signed certificate, and that a plain TCP
+// client gets no response.
+func TestServerApplyOverTLS(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+	serverConf, err := ServerTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: %v", err)
+	}
+	clientConf, err := ClientTLSConfig(certFile)
+	if err != nil {
+		t.Fatalf("ClientTLSConfig: %v", err)
+	}
+
+	srv := NewServer(NewStore(), "127.0.0.1:0")
+	srv.SetTLSConfig(serverConf)
+	go srv.ListenAndServe()
+	defer srv.Stop()
+	deadline := time.Now().Add(2 * time.Second)
+	for !srv.serving.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !srv.serving.Load() {
+		t.Fatal("server did not start in time")
+	}
+
+	conn, err := Dial(srv.Addr(), clientConf)
+	if err != nil {
+		t.Fatalf("Dial over TLS: %v", err)
+	}
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	sendEnvelope(t, conn, MsgApplyRequest, ApplyRequest{
+		Agents: []AgentDef{{Name: "builder", ID: "abc123", Definition: `(defagent "builder")`}},
+	})
+	var resp ApplyResponse
+	if err := readEnvelope(t, scanner).DecodePayload(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Summary.Created) != 1 || resp.Summary.Created[0] != "builder" {
+		t.Fatalf("unexpected summary over TLS: %+v", resp)
+	}
+
+	sc, err := NewSteerClientTLS(srv.Addr(), clientConf)
+	if err != nil {
+		t.Fatalf("NewSteerClientTLS: %v", err)
+	}
+	defer sc.Close()
+	select {
+	case state := <-sc.StateCh:
+		if len(state.Objects) != 1 {
+			t.Errorf("expected 1 object in steer state, got %d", len(state.Objects))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no steer state over TLS")
+	}
+
+	plain, plainScanner := dial(t, srv.Addr())
+	defer plain.Close()
+	sendEnvelope(t, plain, MsgHistoryRequest, HistoryRequest{})
+	plain.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	if plainScanner.Scan() && json.Valid(plainScanner.Bytes()) {
+		t.Error("plain TCP client should not get a protocol response from a TLS master")
+	}
+}
@@ -66,6 +66,18 @@ func reconnectSub(client *cluster.SteerClient) app.Sub {
 	}
 }
 
+func replaySub(client *cluster.SteerClient) app.Sub {
+	return func(send func(app.Msg)) app.Msg {
+		for {
+			r, ok := <-client.ReplayCh
+			if !ok {
+				return nil
+			}
+			send(replayMsg(r))
+		}
+	}
+}
+
 func tickSub() app.Sub {
 	return func(send func(app.Msg)) app.Msg {
 		for {
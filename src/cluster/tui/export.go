@@ -0,0 +1,31 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"p2p/cluster"
+)
+
+// exportState writes the last state received from the master — objects,
+// methods, pipelines and runs — to a timestamped JSON file in the current
+// directory, for bug reports and offline analysis. It returns the file name.
+func exportState(mdl *Model, now time.Time) (string, error) {
+	payload := cluster.SteerStatePayload{
+		Objects:   mdl.Objects,
+		Runs:      mdl.Runs,
+		Methods:   mdl.Methods,
+		Pipelines: mdl.Pipelines,
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("gcluster-state-%s.json", now.Format("20060102-150405"))
+	if err := os.WriteFile(name, append(data, '\n'), 0644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
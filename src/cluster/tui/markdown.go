@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/stukennedy/tooey/node"
+)
+
+// A deliberately small markdown renderer for agent output: headings, lists,
+// block quotes, rules and fenced code. Inline emphasis markers are stripped
+// since a text node carries one style. Anything it doesn't recognise passes
+// through as plain text, so the worst case is the raw view. Long lines are
+// wrapped to the pane width by tooey's layout, as for all text nodes.
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdBullet   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdNumbered = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdRule     = regexp.MustCompile(`^(?:-\s*){3,}$|^(?:\*\s*){3,}$|^(?:_\s*){3,}$`)
+	mdEmphasis = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	mdCode     = regexp.MustCompile("`([^`]+)`")
+)
+
+// renderMarkdown renders text as display nodes, one per source line.
+// prefix is prepended to the first line and an equal-width indent to the
+// rest, so the block lines up under a bullet. If rendering panics on odd
+// input, it falls back to the raw lines.
+func renderMarkdown(text, prefix string) (out []node.Node) {
+	defer func() {
+		if recover() != nil {
+			out = renderRaw(text, prefix)
+		}
+	}()
+
+	indent := strings.Repeat(" ", len([]rune(prefix)))
+	first := true
+	lead := func() string {
+		if first {
+			first = false
+			return prefix
+		}
+		return indent
+	}
+
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			out = append(out, node.TextStyled(lead()+"│ "+line, 6, 0, 0))
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			out = append(out, node.Text(lead()))
+		case mdRule.MatchString(trimmed):
+			out = append(out, node.TextStyled(lead()+strings.Repeat("─", 40), 8, 0, 0))
+		case mdHeading.MatchString(trimmed):
+			m := mdHeading.FindStringSubmatch(trimmed)
+			title := stripInline(m[2])
+			if len(m[1]) == 1 {
+				out = append(out, node.TextStyled(lead()+title, 0, 0, node.Bold|node.Underline))
+			} else {
+				out = append(out, node.TextStyled(lead()+title, 0, 0, node.Bold))
+			}
+		case strings.HasPrefix(trimmed, ">"):
+			quote := stripInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+			out = append(out, node.TextStyled(lead()+"┃ "+quote, 8, 0, node.Italic))
+		case mdBullet.MatchString(line):
+			m := mdBullet.FindStringSubmatch(line)
+			out = append(out, node.Text(lead()+m[1]+"• "+stripInline(m[2])))
+		case mdNumbered.MatchString(line):
+			m := mdNumbered.FindStringSubmatch(line)
+			out = append(out, node.Text(lead()+m[1]+m[2]+" "+stripInline(m[3])))
+		default:
+			out = append(out, node.Text(lead()+stripInline(line)))
+		}
+	}
+	return out
+}
+
+// renderRaw is the unrendered view: text lines as-is under the prefix.
+func renderRaw(text, prefix string) []node.Node {
+	indent := strings.Repeat(" ", len([]rune(prefix)))
+	lines := strings.Split(text, "\n")
+	out := []node.Node{node.Text(prefix + lines[0])}
+	for _, line := range lines[1:] {
+		out = append(out, node.Text(indent+line))
+	}
+	return out
+}
+
+// stripInline removes **bold**, __bold__ and `code` markers.
+func stripInline(s string) string {
+	s = mdEmphasis.ReplaceAllString(s, "$2")
+	return mdCode.ReplaceAllString(s, "$1")
+}
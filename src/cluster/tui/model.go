@@ -5,6 +5,7 @@
 package tui
 
 import (
+	"fmt"
 	"math"
 	"strings"
 
@@ -43,6 +44,7 @@ func (e errMsg) Error() string { return e.err.Error() }
 
 type reconnectMsg struct{}
 type tickMsg struct{}
+type replayMsg cluster.SteerReplayResult
 
 const (
 	focusSidebar = "sidebar"
@@ -59,25 +61,47 @@ type Model struct {
 	Runs      map[string]cluster.AgentRunSnapshot
 	Methods   map[string]map[string]string
 	Pipelines map[string]*cluster.PipelineDef
+	// Unhealthy holds agents whose recent iterations all failed.
+	Unhealthy map[string]bool
 
 	// Sidebar
 	Cursor        int
 	SidebarScroll int
 	Search        string
 	Expanded      map[string]bool
+	// Searching routes keys into the search input ('/' starts it, Enter
+	// keeps the filter, Esc clears it).
+	Searching bool
 
 	// Content scroll (offset = lines from bottom in ScrollToBottom mode)
 	Scroll int
 	Tail   bool
 
+	// RawOutput shows iteration text as-is instead of rendered markdown.
+	RawOutput bool
+
 	// Inputs
 	SearchInput component.TextInput
 	MsgInput    component.TextInput
 	PromptInput component.TextInput
 
-	// Focus + status
+	// ReadOnly hides the input box and disables keys that change agents
+	// (set when the client subscribed with `gcluster steer --read-only`).
+	ReadOnly bool
+
+	// Replay is the latest replay result, shown under the iteration it
+	// replayed.
+	Replay *cluster.SteerReplayResult
+
+	// ConfirmDelete names the agent awaiting delete confirmation ("" when
+	// no confirmation is pending).
+	ConfirmDelete string
+
+	// Focus + status. Flash is a one-off status message (e.g. an export's
+	// file name), cleared by the next key press.
 	Focused   string
 	ErrText   string
+	Flash     string
 	Ready     bool
 	Started   bool
 	SpinFrame int
@@ -88,6 +112,7 @@ func NewModel(client *cluster.SteerClient) *Model {
 	return &Model{
 		Client:      client,
 		Tail:        true,
+		ReadOnly:    client != nil && client.ReadOnly(),
 		Runs:        make(map[string]cluster.AgentRunSnapshot),
 		Methods:     make(map[string]map[string]string),
 		Pipelines:   make(map[string]*cluster.PipelineDef),
@@ -122,6 +147,12 @@ func expandKey(e Entry) string {
 	return entryKey(e.Agent, "")
 }
 
+// selectionKey identifies a sidebar entry independently of its row index,
+// so the cursor can follow the same node when a state push reshapes the tree.
+func selectionKey(e Entry) string {
+	return fmt.Sprintf("%d/%s/%s/%d", e.Kind, e.Agent, e.Step, e.Iter)
+}
+
 func isExpanded(expanded map[string]bool, key string) bool {
 	v, ok := expanded[key]
 	return !ok || v
@@ -159,6 +190,8 @@ func stateLabel(s cluster.RunState) string {
 		return "running"
 	case cluster.RunStateStopped:
 		return "stopped"
+	case cluster.RunStateScheduled:
+		return "scheduled"
 	default:
 		return string(s)
 	}
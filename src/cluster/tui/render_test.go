@@ -344,3 +344,33 @@ func TestRender_FullSpecExample(t *testing.T) {
 	fmt.Println("└────────────────────────────────────────────────────────┘")
 	fmt.Println()
 }
+
+func TestRender_Markdown(t *testing.T) {
+	md := "# Plan\n\nSome **bold** and `code`.\n\n- first item\n- second item\n\n```go\nfunc main() {}\n```\n\n> quoted\n\n---"
+	msgs := []cluster.ConvoMessage{{ID: "msg-1", Type: "text", Content: md}}
+
+	out := renderToText(renderConversation(msgs, false))
+	for _, want := range []string{"● Plan", "Some bold and code.", "• first item", "│ func main() {}", "┃ quoted"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "```") || strings.Contains(out, "**") {
+		t.Errorf("markdown markers should be rendered away:\n%s", out)
+	}
+
+	raw := renderToText(renderConversation(msgs, true))
+	for _, want := range []string{"● # Plan", "Some **bold** and `code`.", "```go"} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("raw output missing %q:\n%s", want, raw)
+		}
+	}
+}
+
+func TestRender_MarkdownMalformed(t *testing.T) {
+	// Unclosed fences, stray markers and empty input must not panic.
+	for _, md := range []string{"```", "**unclosed", "#", "- ", "1.", ">", "", "* * *", strings.Repeat("x", 500)} {
+		msgs := []cluster.ConvoMessage{{ID: "msg-1", Type: "text", Content: md}}
+		renderConversation(msgs, false)
+	}
+}
@@ -16,11 +16,10 @@ func deriveTree(objects []cluster.ClusterObject, runs map[string]cluster.AgentRu
 	copy(sorted, objects)
 	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
 
-	searchLower := strings.ToLower(search)
 	var entries []Entry
 
 	for _, obj := range sorted {
-		if search != "" && !strings.Contains(strings.ToLower(obj.Name), searchLower) {
+		if !matchesSearch(obj, search) {
 			continue
 		}
 
@@ -47,6 +46,9 @@ func deriveTree(objects []cluster.ClusterObject, runs map[string]cluster.AgentRu
 				case cluster.StepKindMap:
 					stepLabel = step.MapMethod
 					label = fmt.Sprintf("map(%s)", stepLabel)
+				case cluster.StepKindReduce:
+					stepLabel = step.ReduceMethod
+					label = fmt.Sprintf("reduce(%s)", stepLabel)
 				case cluster.StepKindSimple:
 					stepLabel = step.Method
 					label = step.Label
@@ -109,3 +111,18 @@ func appendIters(entries *[]Entry, agent, step string, run cluster.AgentRunSnaps
 		}
 	}
 }
+
+// matchesSearch reports whether an agent passes the sidebar search.
+// "label:key=value" matches agents with that label, "label:key" agents
+// with the key set; anything else is a case-insensitive name substring.
+func matchesSearch(obj cluster.ClusterObject, search string) bool {
+	if search == "" {
+		return true
+	}
+	if sel, ok := strings.CutPrefix(search, "label:"); ok {
+		key, value, hasValue := strings.Cut(sel, "=")
+		v, set := obj.Labels[key]
+		return set && (!hasValue || v == value)
+	}
+	return strings.Contains(strings.ToLower(obj.Name), strings.ToLower(search))
+}
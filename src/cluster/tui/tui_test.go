@@ -1,7 +1,9 @@
 package tui
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -240,6 +242,59 @@ func TestUpdateStateMsg(t *testing.T) {
 	}
 }
 
+func TestUpdateStatePreservesCollapse(t *testing.T) {
+	objects := []cluster.ClusterObject{
+		{Name: "builder", Definition: `(defagent "builder" (pipeline (step "build" (loop build))))`},
+	}
+	mdl := NewModel(nil)
+	mdl.Started = true
+	mdl.Focused = focusSidebar
+	tuiUpdate(mdl, stateMsg(cluster.SteerStatePayload{Objects: objects}))
+
+	// Collapse the agent, then receive another push.
+	r := tuiUpdate(mdl, app.KeyMsg{Key: input.Key{Type: input.Left}})
+	r = tuiUpdate(r.Model, stateMsg(cluster.SteerStatePayload{
+		Objects: objects,
+		Runs: map[string]cluster.AgentRunSnapshot{"builder": {Name: "builder", Iterations: []cluster.IterationResult{
+			{Iteration: 1, StartedAt: time.Now(), FinishedAt: time.Now()},
+		}}},
+	}))
+	m := r.Model.(*Model)
+	if n := len(deriveTree(m.Objects, m.Runs, m.Pipelines, m.Search, m.Expanded)); n != 1 {
+		t.Fatalf("collapsed agent should stay collapsed after push, got %d entries", n)
+	}
+}
+
+func TestUpdateStateKeepsCursorOnNode(t *testing.T) {
+	objects := []cluster.ClusterObject{
+		{Name: "builder", Definition: `(defagent "builder" (pipeline (step "build" (loop build))))`},
+	}
+	iter := func(n int) cluster.IterationResult {
+		return cluster.IterationResult{Iteration: n, StartedAt: time.Now(), FinishedAt: time.Now()}
+	}
+	mdl := NewModel(nil)
+	mdl.Started = true
+	tuiUpdate(mdl, stateMsg(cluster.SteerStatePayload{
+		Objects: objects,
+		Runs: map[string]cluster.AgentRunSnapshot{"builder": {Name: "builder",
+			Iterations: []cluster.IterationResult{iter(1)}}},
+	}))
+	mdl.Cursor = 2 // iteration 1
+
+	// A new iteration is listed above iteration 1; the cursor must follow it.
+	r := tuiUpdate(mdl, stateMsg(cluster.SteerStatePayload{
+		Objects: objects,
+		Runs: map[string]cluster.AgentRunSnapshot{"builder": {Name: "builder",
+			LiveIter:   &cluster.IterationResult{Iteration: 3, StartedAt: time.Now()},
+			Iterations: []cluster.IterationResult{iter(1), iter(2)}}},
+	}))
+	m := r.Model.(*Model)
+	entries := deriveTree(m.Objects, m.Runs, m.Pipelines, m.Search, m.Expanded)
+	if got := entries[m.Cursor]; got.Kind != NodeIteration || got.Iter != 1 {
+		t.Fatalf("cursor should stay on iteration 1, got %+v", got)
+	}
+}
+
 func TestUpdateErrAndReconnect(t *testing.T) {
 	mdl := NewModel(nil)
 	mdl.Started = true
@@ -275,6 +330,265 @@ func TestSidebarNav(t *testing.T) {
 	}
 }
 
+func TestDeriveTreeLabelSearch(t *testing.T) {
+	objects := []cluster.ClusterObject{
+		{Name: "api", Labels: map[string]string{"team": "backend", "env": "staging"}},
+		{Name: "web", Labels: map[string]string{"team": "frontend"}},
+		{Name: "misc"},
+	}
+	names := func(search string) []string {
+		var out []string
+		for _, e := range deriveTree(objects, nil, nil, search, map[string]bool{}) {
+			if e.Kind == NodeAgent {
+				out = append(out, e.Agent)
+			}
+		}
+		return out
+	}
+
+	if got := names("label:team=backend"); len(got) != 1 || got[0] != "api" {
+		t.Errorf("label:team=backend = %v, want [api]", got)
+	}
+	if got := names("label:team"); len(got) != 2 {
+		t.Errorf("label:team should match both labelled agents, got %v", got)
+	}
+	if got := names("label:env=prod"); len(got) != 0 {
+		t.Errorf("label:env=prod should match nothing, got %v", got)
+	}
+	if got := names("WE"); len(got) != 1 || got[0] != "web" {
+		t.Errorf("name search should still work, got %v", got)
+	}
+}
+
+func TestSidebarSearchKeys(t *testing.T) {
+	mdl := NewModel(nil)
+	mdl.Started = true
+	mdl.Focused = focusSidebar
+	mdl.Objects = []cluster.ClusterObject{
+		{Name: "api", Labels: map[string]string{"team": "backend"}},
+		{Name: "web", Labels: map[string]string{"team": "frontend"}},
+	}
+	press := func(k input.Key) {
+		t.Helper()
+		r := tuiUpdate(mdl, app.KeyMsg{Key: k})
+		if r.Model == nil {
+			t.Fatalf("key %+v quit the TUI", k)
+		}
+		mdl = r.Model.(*Model)
+	}
+	agents := func() []string {
+		var out []string
+		for _, e := range deriveTree(mdl.Objects, mdl.Runs, mdl.Pipelines, mdl.Search, mdl.Expanded) {
+			if e.Kind == NodeAgent {
+				out = append(out, e.Agent)
+			}
+		}
+		return out
+	}
+
+	press(input.Key{Type: input.RuneKey, Rune: '/'})
+	// Runes that are sidebar shortcuts (q, j, k, c, ...) are typed, not run.
+	for _, r := range "label:team=backend" {
+		press(input.Key{Type: input.RuneKey, Rune: r})
+	}
+	if mdl.Search != "label:team=backend" {
+		t.Fatalf("search = %q, want the typed filter", mdl.Search)
+	}
+	if got := agents(); len(got) != 1 || got[0] != "api" {
+		t.Errorf("typed label filter should leave [api], got %v", got)
+	}
+
+	press(input.Key{Type: input.Enter})
+	if mdl.Searching || mdl.Search != "label:team=backend" {
+		t.Errorf("Enter should keep the filter and leave search, got searching=%v search=%q", mdl.Searching, mdl.Search)
+	}
+	press(input.Key{Type: input.RuneKey, Rune: 'j'})
+	if mdl.Search != "label:team=backend" {
+		t.Errorf("keys after Enter should not edit the search, got %q", mdl.Search)
+	}
+
+	press(input.Key{Type: input.RuneKey, Rune: '/'})
+	press(input.Key{Type: input.Escape})
+	if mdl.Searching || mdl.Search != "" || len(agents()) != 2 {
+		t.Errorf("Esc should clear the filter, got search=%q agents=%v", mdl.Search, agents())
+	}
+}
+
+func TestReplayResultShownUnderIteration(t *testing.T) {
+	mdl := NewModel(nil)
+	mdl.Started = true
+	mdl.Runs = map[string]cluster.AgentRunSnapshot{"builder": {Name: "builder",
+		Iterations: []cluster.IterationResult{{Iteration: 1, FinishedAt: time.Now()}}}}
+	entry := Entry{Kind: NodeIteration, Agent: "builder", Step: "build", Iter: 1}
+
+	// R with nothing typed explains how to replay instead of sending.
+	replayKey(mdl, entry)
+	if mdl.Flash == "" || mdl.Replay != nil {
+		t.Errorf("R with an empty input should only flash a hint, got flash=%q replay=%+v", mdl.Flash, mdl.Replay)
+	}
+
+	r := tuiUpdate(mdl, replayMsg(cluster.SteerReplayResult{AgentName: "builder", Iteration: 1, Output: "replayed output"}))
+	mdl = r.Model.(*Model)
+	if got := renderToText(renderIteration(entry, mdl)); !strings.Contains(got, "Replay") || !strings.Contains(got, "replayed output") {
+		t.Errorf("iteration view should show the replay result, got:\n%s", got)
+	}
+	other := Entry{Kind: NodeIteration, Agent: "builder", Step: "build", Iter: 2}
+	mdl.Runs["builder"] = cluster.AgentRunSnapshot{Name: "builder", Iterations: []cluster.IterationResult{{Iteration: 1}, {Iteration: 2}}}
+	if got := renderToText(renderIteration(other, mdl)); strings.Contains(got, "replayed output") {
+		t.Errorf("the replay should only show under the iteration it replayed, got:\n%s", got)
+	}
+}
+
+func TestSidebarDeleteNeedsConfirmation(t *testing.T) {
+	mdl := NewModel(nil)
+	mdl.Started = true
+	mdl.Ready = true
+	mdl.Focused = focusSidebar
+	mdl.Objects = []cluster.ClusterObject{
+		{Name: "a", Definition: `(defagent "a" (pipeline (step "s" (loop s))))`},
+	}
+
+	r := tuiUpdate(mdl, app.KeyMsg{Key: input.Key{Type: input.RuneKey, Rune: 'D'}})
+	m := r.Model.(*Model)
+	if m.ConfirmDelete != "a" {
+		t.Fatalf("D on an agent should ask for confirmation, got %q", m.ConfirmDelete)
+	}
+
+	// Any key other than y cancels — and is swallowed, so q doesn't quit.
+	r = tuiUpdate(m, app.KeyMsg{Key: input.Key{Type: input.RuneKey, Rune: 'q'}})
+	if r.Model == nil {
+		t.Fatal("key during confirmation should not quit")
+	}
+	if m = r.Model.(*Model); m.ConfirmDelete != "" {
+		t.Errorf("confirmation should be cancelled, got %q", m.ConfirmDelete)
+	}
+}
+
+func TestSidebarDeleteReadOnly(t *testing.T) {
+	mdl := NewModel(nil)
+	mdl.Started = true
+	mdl.Ready = true
+	mdl.ReadOnly = true
+	mdl.Focused = focusSidebar
+	mdl.Objects = []cluster.ClusterObject{
+		{Name: "a", Definition: `(defagent "a" (pipeline (step "s" (loop s))))`},
+	}
+
+	r := tuiUpdate(mdl, app.KeyMsg{Key: input.Key{Type: input.RuneKey, Rune: 'D'}})
+	if m := r.Model.(*Model); m.ConfirmDelete != "" {
+		t.Errorf("D should do nothing in read-only mode, got confirmation for %q", m.ConfirmDelete)
+	}
+}
+
+func TestFoldAll(t *testing.T) {
+	mdl := NewModel(nil)
+	mdl.Started = true
+	mdl.Ready = true
+	mdl.Focused = focusSidebar
+	mdl.Objects = []cluster.ClusterObject{
+		{Name: "a", Definition: `(defagent "a" (pipeline (step "s" (loop s))))`},
+		{Name: "b", Definition: `(defagent "b" (pipeline (step "s" (loop s))))`},
+		{Name: "c", Definition: `(defagent "c" (pipeline (step "s" (loop s))))`},
+	}
+	mdl.Runs = map[string]cluster.AgentRunSnapshot{
+		"b": {Name: "b", Iterations: []cluster.IterationResult{{Iteration: 1}, {Iteration: 2}}},
+	}
+	tree := func(m *Model) []Entry {
+		return deriveTree(m.Objects, m.Runs, m.Pipelines, m.Search, m.Expanded)
+	}
+	full := len(tree(mdl))
+	mdl.Cursor = 4 // b's first iteration
+
+	r := tuiUpdate(mdl, app.KeyMsg{Key: input.Key{Type: input.RuneKey, Rune: 'c'}})
+	m := r.Model.(*Model)
+	entries := tree(m)
+	if len(entries) != 3 {
+		t.Fatalf("expected only the 3 agent roots after collapsing all, got %d entries", len(entries))
+	}
+	if e := entries[m.Cursor]; e.Kind != NodeAgent || e.Agent != "b" {
+		t.Errorf("cursor should move to the collapsed agent b, got %+v", e)
+	}
+
+	r = tuiUpdate(m, app.KeyMsg{Key: input.Key{Type: input.RuneKey, Rune: 'C'}})
+	m = r.Model.(*Model)
+	if n := len(tree(m)); n != full {
+		t.Errorf("expected %d entries after expanding all, got %d", full, n)
+	}
+
+	m.Focused = focusInput
+	r = tuiUpdate(m, app.KeyMsg{Key: input.Key{Type: input.RuneKey, Rune: 'c'}})
+	m = r.Model.(*Model)
+	if n := len(tree(m)); n != full {
+		t.Errorf("c should be ignored while the input is focused, got %d entries", n)
+	}
+}
+
+func TestClusterStats(t *testing.T) {
+	mdl := NewModel(nil)
+	if got, want := clusterStats(mdl), "0 agents · 0 iterations"; got != want {
+		t.Errorf("empty cluster: got %q, want %q", got, want)
+	}
+
+	mdl.Objects = []cluster.ClusterObject{
+		{Name: "a", State: cluster.RunStateRunning},
+		{Name: "b", State: cluster.RunStateRunning},
+		{Name: "c", State: cluster.RunStatePending},
+		{Name: "d", State: cluster.RunStateStopped},
+	}
+	mdl.Runs = map[string]cluster.AgentRunSnapshot{
+		"a": {Name: "a", Iterations: []cluster.IterationResult{{Iteration: 11}, {Iteration: 12}},
+			Usage: cluster.Usage{CostUSD: 1.25}},
+		"b": {Name: "b", Iterations: []cluster.IterationResult{{Iteration: 1}},
+			Usage: cluster.Usage{CostUSD: 0.5}},
+		"c": {Name: "c"},
+	}
+	mdl.Unhealthy = map[string]bool{"b": true}
+
+	want := "4 agents: 2 running, 1 pending, 1 stopped · 1 unhealthy · 13 iterations · $1.75"
+	if got := clusterStats(mdl); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExportState(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	mdl := NewModel(nil)
+	mdl.Started = true
+	mdl.Ready = true
+	mdl.Focused = focusSidebar
+	mdl.Objects = []cluster.ClusterObject{{Name: "a", State: cluster.RunStateRunning}}
+	mdl.Methods = map[string]map[string]string{"a": {"build": "do work"}}
+
+	r := tuiUpdate(mdl, app.KeyMsg{Key: input.Key{Type: input.RuneKey, Rune: 'x'}})
+	m := r.Model.(*Model)
+	if m.ErrText != "" {
+		t.Fatalf("unexpected export error: %s", m.ErrText)
+	}
+	name := strings.TrimPrefix(m.Flash, "exported state to ")
+	if name == m.Flash || !strings.HasPrefix(name, "gcluster-state-") {
+		t.Fatalf("expected export file name in flash, got %q", m.Flash)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	var p cluster.SteerStatePayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if len(p.Objects) != 1 || p.Objects[0].Name != "a" || p.Methods["a"]["build"] != "do work" {
+		t.Errorf("unexpected exported state: %+v", p)
+	}
+
+	// The flash clears on the next key press.
+	r = tuiUpdate(m, app.KeyMsg{Key: input.Key{Type: input.RuneKey, Rune: 'j'}})
+	if m = r.Model.(*Model); m.Flash != "" {
+		t.Errorf("flash should clear on next key, got %q", m.Flash)
+	}
+}
+
 func TestQuit(t *testing.T) {
 	for _, pane := range []string{focusSidebar, ""} {
 		mdl := NewModel(nil)
@@ -309,6 +623,11 @@ func TestHelpers(t *testing.T) {
 	if expandKey(Entry{Kind: NodeAgent, Agent: "a"}) != "a/" {
 		t.Error("expandKey agent")
 	}
+	for n, want := range map[int64]string{950: "950", 8200: "8.2k", 1_500_000: "1.5M"} {
+		if got := formatTokens(n); got != want {
+			t.Errorf("formatTokens(%d) = %q, want %q", n, got, want)
+		}
+	}
 
 	scroll := 0
 	ensureVisible(&scroll, 15, 10)
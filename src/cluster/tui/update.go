@@ -2,10 +2,12 @@ package tui
 
 import (
 	"fmt"
+	"time"
 
 	"p2p/cluster"
 
 	"github.com/stukennedy/tooey/app"
+	"github.com/stukennedy/tooey/component"
 	"github.com/stukennedy/tooey/input"
 )
 
@@ -15,6 +17,12 @@ func tuiUpdate(m interface{}, msg app.Msg) app.UpdateResult {
 	switch msg := msg.(type) {
 	case stateMsg:
 		p := cluster.SteerStatePayload(msg)
+		// Remember which node is selected so the cursor can follow it
+		// after the push reshapes the tree (e.g. a new iteration appears).
+		var selKey string
+		if before := deriveTree(mdl.Objects, mdl.Runs, mdl.Pipelines, mdl.Search, mdl.Expanded); len(before) > 0 {
+			selKey = selectionKey(before[clamp(mdl.Cursor, 0, len(before)-1)])
+		}
 		mdl.Objects = p.Objects
 		if p.Runs != nil {
 			mdl.Runs = p.Runs
@@ -25,6 +33,18 @@ func tuiUpdate(m interface{}, msg app.Msg) app.UpdateResult {
 		if p.Pipelines != nil {
 			mdl.Pipelines = p.Pipelines
 		}
+		mdl.Unhealthy = make(map[string]bool, len(p.Unhealthy))
+		for _, name := range p.Unhealthy {
+			mdl.Unhealthy[name] = true
+		}
+		if selKey != "" {
+			for i, e := range deriveTree(mdl.Objects, mdl.Runs, mdl.Pipelines, mdl.Search, mdl.Expanded) {
+				if selectionKey(e) == selKey {
+					mdl.Cursor = i
+					break
+				}
+			}
+		}
 		mdl.Ready = true
 		return app.NoCmd(mdl)
 
@@ -40,6 +60,11 @@ func tuiUpdate(m interface{}, msg app.Msg) app.UpdateResult {
 		mdl.SpinFrame++
 		return app.NoCmd(mdl)
 
+	case replayMsg:
+		r := cluster.SteerReplayResult(msg)
+		mdl.Replay = &r
+		return app.NoCmd(mdl)
+
 	case app.KeyMsg:
 		return handleKey(mdl, msg)
 
@@ -56,7 +81,7 @@ func tuiUpdate(m interface{}, msg app.Msg) app.UpdateResult {
 		result := app.UpdateResult{Model: mdl, Cmds: []app.Cmd{disableMouseCmd}}
 		result.Subs = []app.Sub{tickSub()}
 		if mdl.Client != nil {
-			result.Subs = append(result.Subs, stateSub(mdl.Client), errSub(mdl.Client), reconnectSub(mdl.Client))
+			result.Subs = append(result.Subs, stateSub(mdl.Client), errSub(mdl.Client), reconnectSub(mdl.Client), replaySub(mdl.Client))
 		}
 		return result
 	}
@@ -66,9 +91,20 @@ func tuiUpdate(m interface{}, msg app.Msg) app.UpdateResult {
 func handleKey(mdl *Model, msg app.KeyMsg) app.UpdateResult {
 	entries := deriveTree(mdl.Objects, mdl.Runs, mdl.Pipelines, mdl.Search, mdl.Expanded)
 	sel := clamp(mdl.Cursor, 0, len(entries)-1)
+	mdl.Flash = ""
+
+	if mdl.ConfirmDelete != "" {
+		return handleConfirmDeleteKey(mdl, msg)
+	}
+	if mdl.Searching {
+		return handleSearchKey(mdl, msg)
+	}
 
 	switch mdl.Focused {
 	case focusInput:
+		if mdl.ReadOnly {
+			return app.NoCmd(mdl)
+		}
 		return handleInputKey(mdl, msg, entries, sel)
 	case focusSidebar:
 		return handleSidebarKey(mdl, msg, entries, sel)
@@ -107,8 +143,33 @@ func handleSidebarKey(mdl *Model, msg app.KeyMsg, entries []Entry, sel int) app.
 		case 'j':
 			moveCursor(1)
 		case '/':
-			mdl.SearchInput = mdl.SearchInput.Update(msg.Key)
-			mdl.Search = mdl.SearchInput.Value
+			mdl.Searching = true
+		case 'm':
+			mdl.RawOutput = !mdl.RawOutput
+		case 'x':
+			exportKey(mdl)
+		case 'c':
+			foldAll(mdl, entries, sel, false)
+		case 'C', '*':
+			foldAll(mdl, entries, sel, true)
+		case 'D':
+			if !mdl.ReadOnly && sel >= 0 && sel < len(entries) && entries[sel].Kind == NodeAgent {
+				mdl.ConfirmDelete = entries[sel].Agent
+			}
+		case '+', '-':
+			if !mdl.ReadOnly && sel >= 0 && sel < len(entries) {
+				delayKey(mdl, entries[sel].Agent, msg.Key.Rune == '+')
+			}
+		case 'R':
+			if !mdl.ReadOnly && sel >= 0 && sel < len(entries) && entries[sel].Kind == NodeIteration {
+				replayKey(mdl, entries[sel])
+			}
+		case 'S':
+			if !mdl.ReadOnly && sel >= 0 && sel < len(entries) && entries[sel].Kind == NodeIteration && entries[sel].Live && mdl.Client != nil {
+				if err := mdl.Client.SkipIteration(entries[sel].Agent, entries[sel].Iter); err != nil {
+					mdl.ErrText = fmt.Sprintf("skip error: %v", err)
+				}
+			}
 		}
 	case input.Up:
 		moveCursor(-1)
@@ -138,6 +199,119 @@ func handleSidebarKey(mdl *Model, msg app.KeyMsg, entries []Entry, sel int) app.
 	return app.NoCmd(mdl)
 }
 
+// foldAll collapses every agent or expands every node, then moves the
+// cursor to the agent that held it so it stays on a visible row.
+func foldAll(mdl *Model, entries []Entry, sel int, expand bool) {
+	if expand {
+		clear(mdl.Expanded) // nodes are expanded unless marked otherwise
+	} else {
+		for _, obj := range mdl.Objects {
+			mdl.Expanded[entryKey(obj.Name, "")] = false
+		}
+	}
+	if sel < 0 || sel >= len(entries) {
+		return
+	}
+	agent := entries[sel].Agent
+	after := deriveTree(mdl.Objects, mdl.Runs, mdl.Pipelines, mdl.Search, mdl.Expanded)
+	mdl.Cursor = clamp(mdl.Cursor, 0, len(after)-1)
+	for i, e := range after {
+		if e.Kind == NodeAgent && e.Agent == agent {
+			mdl.Cursor = i
+			break
+		}
+	}
+	mdl.Scroll = 0
+	mdl.Tail = true
+}
+
+// handleConfirmDeleteKey resolves a pending delete: 'y' deletes the agent,
+// any other key cancels.
+// handleSearchKey edits the sidebar search while it is active. The tree
+// is filtered as the user types.
+func handleSearchKey(mdl *Model, msg app.KeyMsg) app.UpdateResult {
+	switch msg.Key.Type {
+	case input.Enter:
+		mdl.Searching = false
+		return app.NoCmd(mdl)
+	case input.Escape:
+		mdl.Searching = false
+		mdl.SearchInput = component.NewTextInput(mdl.SearchInput.Placeholder)
+	default:
+		mdl.SearchInput = mdl.SearchInput.Update(msg.Key)
+	}
+	if mdl.Search != mdl.SearchInput.Value {
+		mdl.Search = mdl.SearchInput.Value
+		mdl.Cursor = 0
+		mdl.SidebarScroll = 0
+	}
+	return app.NoCmd(mdl)
+}
+
+func handleConfirmDeleteKey(mdl *Model, msg app.KeyMsg) app.UpdateResult {
+	name := mdl.ConfirmDelete
+	mdl.ConfirmDelete = ""
+	if msg.Key.Type == input.RuneKey && msg.Key.Rune == 'y' && mdl.Client != nil {
+		if err := mdl.Client.DeleteAgent(name); err != nil {
+			mdl.ErrText = fmt.Sprintf("delete error: %v", err)
+		}
+	}
+	return app.NoCmd(mdl)
+}
+
+// replayKey re-runs an iteration with the prompt typed in the input box,
+// leaving the agent's loop alone. The result arrives as a replayMsg.
+func replayKey(mdl *Model, entry Entry) {
+	if mdl.MsgInput.Value == "" {
+		mdl.Flash = "type a prompt in the input box, then R to replay with it"
+		return
+	}
+	if mdl.Client == nil {
+		return
+	}
+	prompt, ti := mdl.MsgInput.Submit()
+	mdl.MsgInput = ti
+	if err := mdl.Client.Replay(entry.Agent, entry.Iter, prompt); err != nil {
+		mdl.ErrText = fmt.Sprintf("replay error: %v", err)
+		return
+	}
+	mdl.Replay = &cluster.SteerReplayResult{AgentName: entry.Agent, Iteration: entry.Iter}
+	mdl.Flash = fmt.Sprintf("replaying %s iteration %d", entry.Agent, entry.Iter)
+}
+
+// delayStep is how much one +/- press changes an agent's iteration delay.
+const delayStep = 5 * time.Second
+
+// delayKey lengthens or shortens the pause between an agent's iterations
+// by delayStep, never below zero.
+func delayKey(mdl *Model, agent string, longer bool) {
+	if mdl.Client == nil {
+		return
+	}
+	delay := mdl.Runs[agent].Delay
+	if longer {
+		delay += delayStep
+	} else if delay -= delayStep; delay < 0 {
+		delay = 0
+	}
+	if err := mdl.Client.SetInterval(agent, delay); err != nil {
+		mdl.ErrText = fmt.Sprintf("delay error: %v", err)
+		return
+	}
+	mdl.Flash = fmt.Sprintf("%s: %v between iterations", agent, delay)
+}
+
+// exportKey writes the current cluster state to a file and flashes its
+// name, or shows the write error in the banner.
+func exportKey(mdl *Model) {
+	name, err := exportState(mdl, time.Now())
+	if err != nil {
+		mdl.ErrText = fmt.Sprintf("export error: %v", err)
+		return
+	}
+	mdl.Flash = "exported state to " + name
+}
+
 func handleContentKey(mdl *Model, msg app.KeyMsg) app.UpdateResult {
 	scroll := func(delta int) {
 		mdl.Scroll += delta
@@ -159,6 +333,10 @@ func handleContentKey(mdl *Model, msg app.KeyMsg) app.UpdateResult {
 			mdl.Scroll = 0 // bottom
 		case 'g':
 			mdl.Scroll = 99999 // top
+		case 'm':
+			mdl.RawOutput = !mdl.RawOutput
+		case 'x':
+			exportKey(mdl)
 		}
 	case input.Up:
 		scroll(1)
@@ -25,13 +25,52 @@ func tuiView(m interface{}, focused string) node.Node {
 
 	entries := deriveTree(mdl.Objects, mdl.Runs, mdl.Pipelines, mdl.Search, mdl.Expanded)
 	sel := clamp(mdl.Cursor, 0, len(entries)-1)
-	return node.Row(renderSidebar(entries, sel, mdl, focused), renderDetail(entries, sel, mdl, focused))
+	return node.Column(
+		node.Row(renderSidebar(entries, sel, mdl, focused), renderDetail(entries, sel, mdl, focused)).WithFlex(1),
+		node.TextStyled(" "+clusterStats(mdl), 8, 0, 0),
+	)
+}
+
+// clusterStats summarises the whole cluster for the footer: agent counts
+// by state, unhealthy agents, and iterations completed and cost across all
+// runs.
+func clusterStats(mdl *Model) string {
+	counts := make(map[cluster.RunState]int)
+	for _, obj := range mdl.Objects {
+		counts[obj.State]++
+	}
+	var parts []string
+	for _, st := range []cluster.RunState{cluster.RunStateRunning, cluster.RunStateScheduled, cluster.RunStatePending, cluster.RunStateStopped} {
+		if counts[st] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[st], stateLabel(st)))
+		}
+	}
+	s := fmt.Sprintf("%d agents", len(mdl.Objects))
+	if len(parts) > 0 {
+		s += ": " + strings.Join(parts, ", ")
+	}
+	if n := len(mdl.Unhealthy); n > 0 {
+		s += fmt.Sprintf(" · %d unhealthy", n)
+	}
+	iterations := 0
+	var cost float64
+	for _, run := range mdl.Runs {
+		if n := len(run.Iterations); n > 0 {
+			iterations += run.Iterations[n-1].Iteration
+		}
+		cost += run.Usage.CostUSD
+	}
+	s += fmt.Sprintf(" · %d iterations", iterations)
+	if cost > 0 {
+		s += fmt.Sprintf(" · $%.2f", cost)
+	}
+	return s
 }
 
 // --- Sidebar ---
 
 func renderSidebar(entries []Entry, sel int, mdl *Model, focused string) node.Node {
-	mdl.SearchInput.Focused = focused == focusSidebar
+	mdl.SearchInput.Focused = mdl.Searching
 	_, cols := input.TermSize()
 	w := cols*3/10 - 6
 	if w < 14 {
@@ -69,6 +108,8 @@ func renderSidebar(entries []Entry, sel int, mdl *Model, focused string) node.No
 			tree = append(tree, node.TextStyled(label, 230, 62, node.Bold))
 		case i == sel:
 			tree = append(tree, node.TextStyled(label, 0, 0, node.Bold|node.Underline))
+		case e.Kind == NodeAgent && mdl.Unhealthy[e.Agent]:
+			tree = append(tree, node.TextStyled(label, 1, 0, 0))
 		case e.Live:
 			tree = append(tree, node.TextStyled(label, 0, 0, node.Bold))
 		case e.Kind == NodeIteration:
@@ -86,7 +127,15 @@ func renderSidebar(entries []Entry, sel int, mdl *Model, focused string) node.No
 	ensureVisible(&mdl.SidebarScroll, sel, vis)
 
 	treeCol := node.Column(tree...).WithFlex(1).WithScrollOffset(mdl.SidebarScroll)
-	help := node.TextStyled(" ↑↓ nav  ←→ fold  Tab pane  q quit", 8, 0, 0)
+	help := node.TextStyled(" ↑↓ nav  ←→ fold  c/C fold all  S skip  R replay  D delete  +/- delay  m raw  x export  Tab pane  q quit", 8, 0, 0)
+	if mdl.ReadOnly {
+		help = node.TextStyled(" ↑↓ nav  ←→ fold  c/C fold all  m raw  x export  Tab pane  q quit  (read-only)", 8, 0, 0)
+	}
+	if mdl.ConfirmDelete != "" {
+		help = node.TextStyled(fmt.Sprintf(" Delete agent %q? y/N", mdl.ConfirmDelete), 1, 0, node.Bold)
+	} else if mdl.Flash != "" {
+		help = node.TextStyled(" "+mdl.Flash, 2, 0, 0)
+	}
 
 	var all []node.Node
 	all = append(all, header...)
@@ -124,10 +173,23 @@ func renderDetail(entries []Entry, sel int, mdl *Model, focused string) node.Nod
 		content = []node.Node{
 			node.Spacer(),
 			node.TextStyled("  "+entry.Agent, 0, 0, node.Bold),
+		}
+		for _, obj := range mdl.Objects {
+			if obj.Name == entry.Agent && len(obj.Labels) > 0 {
+				content = append(content, node.TextStyled("  "+cluster.FormatLabels(obj.Labels), 6, 0, 0))
+			}
+		}
+		if d := mdl.Runs[entry.Agent].Delay; d > 0 {
+			content = append(content, node.TextStyled(fmt.Sprintf("  %v between iterations", d), 8, 0, 0))
+		}
+		if t := mdl.Runs[entry.Agent].NextRun; !t.IsZero() {
+			content = append(content, node.TextStyled("  next run "+t.Format("2006-01-02 15:04"), 8, 0, 0))
+		}
+		content = append(content,
 			node.Text(""),
 			node.TextStyled("  Select a loop or iteration for details.", 8, 0, 0),
 			node.Spacer(),
-		}
+		)
 	case NodeLoop:
 		content = buildLoopContent(entry, mdl)
 		mdl.PromptInput.Focused = focused == focusInput
@@ -150,7 +212,9 @@ func renderDetail(entries []Entry, sel int, mdl *Model, focused string) node.Nod
 	// and content scrolls above it (like a normal chat UI).
 	var children []node.Node
 	children = append(children, content...)
-	if hasInput {
+	if hasInput && mdl.ReadOnly {
+		children = append(children, node.TextStyled("  read-only", 8, 0, 0))
+	} else if hasInput {
 		children = append(children, inputNode.WithKey(focusInput).WithFocusable())
 	}
 
@@ -195,7 +259,27 @@ func buildLoopContent(entry Entry, mdl *Model) []node.Node {
 		node.TextStyled("  Stats", 0, 0, node.Bold), node.Text(""))
 
 	run, hasRun := mdl.Runs[entry.Agent]
-	if hasRun && len(run.Iterations) > 0 {
+	if sr := setupStep(run, entry.Step); sr != nil {
+		// One-shot setup step: show its timing and result instead of
+		// iteration stats.
+		status := "ok"
+		if sr.Error != "" {
+			status = "failed"
+		}
+		statsLines = append(statsLines,
+			node.Text(fmt.Sprintf("  status          %s", status)),
+			node.Text(fmt.Sprintf("  duration        %.1fs", sr.FinishedAt.Sub(sr.StartedAt).Seconds())))
+		if sr.Error != "" {
+			promptLines = append(promptLines, node.Text(""),
+				node.TextStyled("  Error: "+sr.Error, 1, 0, node.Bold))
+		} else if sr.OutputPreview != "" {
+			promptLines = append(promptLines, node.Text(""),
+				node.TextStyled("  Output", 0, 0, node.Bold))
+			for _, line := range strings.Split(sr.OutputPreview, "\n") {
+				promptLines = append(promptLines, node.TextStyled("  "+line, 8, 0, 0))
+			}
+		}
+	} else if hasRun && len(run.Iterations) > 0 {
 		iters := run.Iterations
 		statsLines = append(statsLines, node.Text(fmt.Sprintf("  iterations      %d", len(iters))))
 		var durations []float64
@@ -210,6 +294,7 @@ func buildLoopContent(entry Entry, mdl *Model) []node.Node {
 				node.Text(fmt.Sprintf("  mean(duration)  %.1fs", m)),
 				node.Text(fmt.Sprintf("  stddev(duration) %.1fs", s)))
 		}
+		statsLines = append(statsLines, usageLines(run, iters)...)
 	} else {
 		statsLines = append(statsLines, node.Text("  iterations      0"))
 	}
@@ -224,6 +309,54 @@ func buildLoopContent(entry Entry, mdl *Model) []node.Node {
 	}
 }
 
+// usageLines shows the run's total tokens and cost, and the mean cost of
+// the iterations shown. Nothing is shown if no usage was reported.
+func usageLines(run cluster.AgentRunSnapshot, iters []cluster.IterationResult) []node.Node {
+	u := run.Usage
+	if u == (cluster.Usage{}) {
+		return nil
+	}
+	lines := []node.Node{
+		node.Text(fmt.Sprintf("  tokens in       %s", formatTokens(u.InputTokens))),
+		node.Text(fmt.Sprintf("  tokens out      %s", formatTokens(u.OutputTokens))),
+		node.Text(fmt.Sprintf("  cost            $%.2f", u.CostUSD)),
+	}
+	var costs []float64
+	for _, ir := range iters {
+		if !ir.FinishedAt.IsZero() {
+			costs = append(costs, ir.CostUSD)
+		}
+	}
+	if len(costs) > 0 {
+		m, _ := meanStddev(costs)
+		lines = append(lines, node.Text(fmt.Sprintf("  mean(cost)      $%.2f", m)))
+	}
+	return lines
+}
+
+// formatTokens abbreviates a token count, e.g. 8200 as "8.2k".
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// setupStep returns the recorded result of the setup step that ran method,
+// or nil if there is none (e.g. the node is the loop step).
+func setupStep(run cluster.AgentRunSnapshot, method string) *cluster.StepResult {
+	for i := range run.SetupSteps {
+		if run.SetupSteps[i].Method == method {
+			return &run.SetupSteps[i]
+		}
+	}
+	return nil
+}
+
 // renderIteration finds the iteration data and renders its conversation.
 func renderIteration(entry Entry, mdl *Model) []node.Node {
 	header := []node.Node{
@@ -256,22 +389,35 @@ func renderIteration(entry Entry, mdl *Model) []node.Node {
 		header = append(header, node.TextStyled("  Error: "+iter.Error, 1, 0, node.Bold), node.Text(""))
 	}
 
-	items := renderConversation(iter.Messages)
+	items := renderConversation(iter.Messages, mdl.RawOutput)
 	result := append(header, items...)
 
+	frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 	if live && iter.FinishedAt.IsZero() {
-		frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 		result = append(result, node.Text(""),
 			node.TextStyled("  "+frames[mdl.SpinFrame%len(frames)]+" Thinking...", 8, 0, 0))
 	}
 
+	if r := mdl.Replay; r != nil && r.AgentName == entry.Agent && r.Iteration == entry.Iter {
+		result = append(result, node.Text(""), node.TextStyled("  Replay", 0, 0, node.Bold))
+		switch {
+		case r.Error != "":
+			result = append(result, node.TextStyled("  Error: "+r.Error, 1, 0, node.Bold))
+		case r.Output == "":
+			result = append(result, node.TextStyled("  "+frames[mdl.SpinFrame%len(frames)]+" Replaying...", 8, 0, 0))
+		default:
+			result = append(result, renderConversation([]cluster.ConvoMessage{{Type: "text", Content: r.Output}}, mdl.RawOutput)...)
+		}
+	}
+
 	return result
 }
 
 // renderConversation turns a stream of ConvoMessages into display nodes.
-// Consecutive text deltas are assembled into blocks. Each block gets a ● bullet.
+// Consecutive text deltas are assembled into blocks. Each block gets a ● bullet
+// and is rendered as markdown, or as-is when raw is set.
 // Tool use gets its own styled line. Tool results with content get ⎿ lines.
-func renderConversation(msgs []cluster.ConvoMessage) []node.Node {
+func renderConversation(msgs []cluster.ConvoMessage, raw bool) []node.Node {
 	var items []node.Node
 	var textBuf strings.Builder
 
@@ -281,11 +427,11 @@ func renderConversation(msgs []cluster.ConvoMessage) []node.Node {
 		if text == "" {
 			return
 		}
-		lines := strings.Split(text, "\n")
 		// First line gets bullet
-		items = append(items, node.Text("  ● "+lines[0]))
-		for _, line := range lines[1:] {
-			items = append(items, node.Text("    "+line))
+		if raw {
+			items = append(items, renderRaw(text, "  ● ")...)
+		} else {
+			items = append(items, renderMarkdown(text, "  ● ")...)
 		}
 		items = append(items, node.Text(""))
 	}
@@ -0,0 +1,228 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Agent lifecycle event names sent to webhooks.
+const (
+	EventStarted   = "started"
+	EventStopped   = "stopped"
+	EventFailed    = "failed"
+	EventCompleted = "completed"
+	// A scheduled agent reports each triggered run of its pipeline with
+	// these rather than completed/failed, since it keeps running on its
+	// schedule afterwards.
+	EventRunCompleted = "run_completed"
+	EventRunFailed    = "run_failed"
+)
+
+// AgentEvent is the JSON payload POSTed to a webhook when an agent changes
+// lifecycle state.
+type AgentEvent struct {
+	Agent     string    `json:"agent"`
+	Event     string    `json:"event"`
+	OldState  RunState  `json:"old_state"`
+	NewState  RunState  `json:"new_state"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HTTPDoer is the part of *http.Client a Webhook uses. Tests substitute a
+// fake to capture payloads without a real server.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Webhook defaults: a slow or dead endpoint must never hold up the master.
+const (
+	DefaultWebhookAttempts = 3
+	DefaultWebhookTimeout  = 5 * time.Second
+	DefaultWebhookBackoff  = 500 * time.Millisecond
+	// DefaultWebhookQueue bounds the events waiting for delivery. Events
+	// beyond it are dropped rather than blocking the master.
+	DefaultWebhookQueue = 256
+)
+
+// Webhook delivers AgentEvents to an HTTP endpoint with a bounded number
+// of attempts, each under its own timeout. Delivery failures are logged
+// and otherwise ignored.
+type Webhook struct {
+	URL      string
+	Client   HTTPDoer
+	Attempts int
+	Timeout  time.Duration
+	Backoff  time.Duration
+
+	// queue feeds the single delivery worker, so events arrive in the
+	// order they happened. The worker is started by the first Notify and
+	// stopped by Close.
+	mu     sync.Mutex
+	queue  chan AgentEvent
+	closed bool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWebhook creates a webhook posting to url with the default client,
+// retry count and timeout.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		URL:      url,
+		Client:   http.DefaultClient,
+		Attempts: DefaultWebhookAttempts,
+		Timeout:  DefaultWebhookTimeout,
+		Backoff:  DefaultWebhookBackoff,
+	}
+}
+
+// Notify queues ev for delivery in the background. Events are delivered one
+// at a time in the order they were queued. It never blocks the caller,
+// which is typically holding the store lock: if the queue is full the event
+// is dropped.
+func (w *Webhook) Notify(ev AgentEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		log.Printf("webhook: closed, dropping %s event for agent %q", ev.Event, ev.Agent)
+		return
+	}
+	if w.queue == nil {
+		w.start()
+	}
+	select {
+	case w.queue <- ev:
+	default:
+		log.Printf("webhook: queue full, dropping %s event for agent %q", ev.Event, ev.Agent)
+	}
+}
+
+// start launches the delivery worker. Caller must hold w.mu.
+func (w *Webhook) start() {
+	queue := make(chan AgentEvent, DefaultWebhookQueue)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	w.queue, w.cancel, w.done = queue, cancel, done
+	go func() {
+		defer close(done)
+		for ev := range queue {
+			if ctx.Err() != nil {
+				log.Printf("webhook: closed, dropping %s event for agent %q", ev.Event, ev.Agent)
+				continue
+			}
+			if err := w.send(ctx, ev); err != nil {
+				log.Printf("webhook: failed to deliver %s event for agent %q: %v", ev.Event, ev.Agent, err)
+			}
+		}
+	}()
+}
+
+// Close stops accepting events and waits up to timeout for the queued ones
+// to be delivered; any still undelivered after that are dropped. The
+// delivery worker has exited when Close returns.
+func (w *Webhook) Close(timeout time.Duration) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	queue, cancel, done := w.queue, w.cancel, w.done
+	w.mu.Unlock()
+	if queue == nil {
+		return // never started
+	}
+
+	close(queue)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		cancel()
+		<-done
+	}
+	cancel()
+}
+
+// Send POSTs ev to the webhook URL, retrying on transport errors and
+// non-2xx responses up to w.Attempts times.
+func (w *Webhook) Send(ev AgentEvent) error {
+	return w.send(context.Background(), ev)
+}
+
+// send is Send, giving up early if ctx is cancelled.
+func (w *Webhook) send(ctx context.Context, ev AgentEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	attempts := w.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(w.Backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if lastErr = w.post(ctx, body); lastErr == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("after %d attempts: %w", attempts, lastErr)
+}
+
+// post makes a single delivery attempt.
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// stateEvent names the lifecycle event for a run state transition.
+// A running agent falling back to pending means it failed to start.
+func stateEvent(from, to RunState) string {
+	switch to {
+	case RunStateRunning:
+		return EventStarted
+	case RunStateStopped:
+		return EventStopped
+	case RunStatePending:
+		if from == RunStateRunning {
+			return EventFailed
+		}
+	}
+	return ""
+}
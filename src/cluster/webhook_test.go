@@ -0,0 +1,258 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDoer records webhook requests and replies with queued status codes
+// (200 once the queue is empty).
+type fakeDoer struct {
+	mu       sync.Mutex
+	statuses []int
+	events   []AgentEvent
+}
+
+func (d *fakeDoer) Do(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	var ev AgentEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, ev)
+	status := http.StatusOK
+	if len(d.statuses) > 0 {
+		status, d.statuses = d.statuses[0], d.statuses[1:]
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+func (d *fakeDoer) snapshot() []AgentEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]AgentEvent(nil), d.events...)
+}
+
+// waitForEvent polls until an event with the given name arrives for agent.
+func (d *fakeDoer) waitForEvent(t *testing.T, agent, event string) AgentEvent {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, ev := range d.snapshot() {
+			if ev.Agent == agent && ev.Event == event {
+				return ev
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no %s event for %q, got %+v", event, agent, d.snapshot())
+	return AgentEvent{}
+}
+
+// doerFunc adapts a function to HTTPDoer.
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func testWebhook(d HTTPDoer) *Webhook {
+	return &Webhook{URL: "http://hooks.test/gcluster", Client: d, Attempts: 3, Timeout: time.Second}
+}
+
+func TestWebhookSendRetries(t *testing.T) {
+	d := &fakeDoer{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	w := testWebhook(d)
+
+	if err := w.Send(AgentEvent{Agent: "builder", Event: EventStarted}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if n := len(d.snapshot()); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestWebhookSendGivesUp(t *testing.T) {
+	d := &fakeDoer{statuses: []int{500, 500, 500, 500}}
+	w := testWebhook(d)
+
+	if err := w.Send(AgentEvent{Agent: "builder", Event: EventStarted}); err == nil {
+		t.Fatal("expected error after exhausting attempts")
+	}
+	if n := len(d.snapshot()); n != 3 {
+		t.Fatalf("expected attempts bounded at 3, got %d", n)
+	}
+}
+
+type errDoer struct{}
+
+func (errDoer) Do(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestWebhookTransportError(t *testing.T) {
+	if err := testWebhook(errDoer{}).Send(AgentEvent{Agent: "a"}); err == nil {
+		t.Fatal("expected transport error to be returned")
+	}
+}
+
+// TestServerWebhookLifecycle verifies that starting, completing and
+// stopping agents all produce webhook events with the right states.
+func TestServerWebhookLifecycle(t *testing.T) {
+	srv, store, cleanup := startTestServerWithExecutor(t, fakeClaude(5*time.Millisecond))
+	defer cleanup()
+
+	d := &fakeDoer{}
+	srv.SetWebhook(testWebhook(d))
+
+	store.ApplyDefinitions([]AgentDef{
+		{Name: "looper", ID: "id-l", Definition: `(defagent "looper")`},
+		{Name: "oneshot", ID: "id-o", Definition: `(defagent "oneshot")`},
+	})
+	srv.executor.SetPipeline("oneshot", &PipelineDef{Steps: []PipelineStep{
+		{Label: "once", Kind: StepKindSimple, Method: "once"},
+	}})
+
+	if err := srv.executor.Start("looper", map[string]string{"work": "do work"}); err != nil {
+		t.Fatalf("start looper: %v", err)
+	}
+	if err := srv.executor.Start("oneshot", map[string]string{"once": "do it"}); err != nil {
+		t.Fatalf("start oneshot: %v", err)
+	}
+
+	started := d.waitForEvent(t, "looper", EventStarted)
+	if started.OldState != RunStatePending || started.NewState != RunStateRunning {
+		t.Errorf("unexpected started transition: %+v", started)
+	}
+	if started.Timestamp.IsZero() {
+		t.Error("event should carry a timestamp")
+	}
+
+	d.waitForEvent(t, "oneshot", EventCompleted)
+
+	// An agent that stops itself reports the stop in its completed event.
+	store.ApplyDefinitions([]AgentDef{{Name: "capped", ID: "id-c", Definition: `(defagent "capped")`}})
+	srv.executor.SetMaxIterations("capped", 1)
+	if err := srv.executor.Start("capped", map[string]string{"work": "do work"}); err != nil {
+		t.Fatalf("start capped: %v", err)
+	}
+	completed := d.waitForEvent(t, "capped", EventCompleted)
+	if completed.OldState != RunStateRunning || completed.NewState != RunStateStopped {
+		t.Errorf("unexpected completed transition: %+v", completed)
+	}
+
+	if err := srv.executor.Stop("looper", time.Second); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	stopped := d.waitForEvent(t, "looper", EventStopped)
+	if stopped.NewState != RunStateStopped {
+		t.Errorf("unexpected stopped transition: %+v", stopped)
+	}
+
+	// A scheduled agent reports each triggered run, not a completion.
+	store.ApplyDefinitions([]AgentDef{{Name: "nightly", ID: "id-n", Definition: `(defagent "nightly")`}})
+	srv.executor.SetPipeline("nightly", &PipelineDef{Steps: []PipelineStep{
+		{Label: "report", Kind: StepKindSimple, Method: "report"},
+	}})
+	srv.executor.SetSchedule("nightly", everyInterval(20*time.Millisecond))
+	if err := srv.executor.Start("nightly", map[string]string{"report": "write a report"}); err != nil {
+		t.Fatalf("start nightly: %v", err)
+	}
+	run := d.waitForEvent(t, "nightly", EventRunCompleted)
+	if run.NewState != RunStateScheduled {
+		t.Errorf("unexpected run_completed transition: %+v", run)
+	}
+	for _, ev := range d.snapshot() {
+		if ev.Agent == "nightly" && ev.Event == EventCompleted {
+			t.Errorf("scheduled run reported as completed: %+v", ev)
+		}
+	}
+}
+
+func TestWebhookNotifyOrder(t *testing.T) {
+	// The first delivery is slow: later events must still arrive after it.
+	d := &fakeDoer{}
+	var calls sync.Map
+	w := testWebhook(doerFunc(func(req *http.Request) (*http.Response, error) {
+		if _, loaded := calls.LoadOrStore("first", true); !loaded {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return d.Do(req)
+	}))
+	defer w.Close(time.Second)
+
+	want := []string{EventStarted, EventFailed, EventStarted, EventStopped}
+	for _, event := range want {
+		w.Notify(AgentEvent{Agent: "builder", Event: event})
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(d.snapshot()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := d.snapshot()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), got)
+	}
+	for i, ev := range got {
+		if ev.Event != want[i] {
+			t.Fatalf("events delivered out of order: got %+v, want %v", got, want)
+		}
+	}
+}
+
+func TestWebhookClose(t *testing.T) {
+	d := &fakeDoer{}
+	w := testWebhook(d)
+	w.Notify(AgentEvent{Agent: "builder", Event: EventStarted})
+	w.Notify(AgentEvent{Agent: "builder", Event: EventStopped})
+
+	// Close delivers what was already queued and stops the worker.
+	w.Close(time.Second)
+	if got := d.snapshot(); len(got) != 2 {
+		t.Fatalf("expected queued events to be delivered before Close returns, got %+v", got)
+	}
+	select {
+	case <-w.done:
+	default:
+		t.Fatal("delivery worker still running after Close")
+	}
+
+	// Later events are dropped rather than sent on the closed queue.
+	w.Notify(AgentEvent{Agent: "builder", Event: EventStarted})
+	w.Close(time.Second)
+	if got := d.snapshot(); len(got) != 2 {
+		t.Errorf("event delivered after Close: %+v", got)
+	}
+}
+
+func TestWebhookCloseGivesUp(t *testing.T) {
+	// A delivery stuck in its retry backoff is abandoned once Close times out.
+	d := &fakeDoer{statuses: []int{http.StatusInternalServerError}}
+	w := testWebhook(d)
+	w.Backoff = time.Hour
+	w.Notify(AgentEvent{Agent: "builder", Event: EventStarted})
+	for len(d.snapshot()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	w.Close(20 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close waited %v for a stuck delivery", elapsed)
+	}
+}
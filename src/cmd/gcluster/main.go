@@ -3,13 +3,18 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
-	"net"
+	"maps"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -24,9 +29,12 @@ import (
 )
 
 var commands = map[string]func(args []string){
-	"apply":  cmdApply,
-	"master": cmdMaster,
-	"steer":  cmdSteer,
+	"apply":    cmdApply,
+	"config":   cmdConfig,
+	"describe": cmdDescribe,
+	"history":  cmdHistory,
+	"master":   cmdMaster,
+	"steer":    cmdSteer,
 }
 
 func main() {
@@ -44,7 +52,7 @@ func main() {
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: gcluster <command> [args...]\n\ncommands:\n  apply    Apply agent definitions from a .p file\n  master   Start the cluster control plane\n  steer    Open the steering TUI\n")
+	fmt.Fprintf(os.Stderr, "usage: gcluster <command> [args...]\n\ncommands:\n  apply    Apply agent definitions from a .p file\n  config   Show the master's effective configuration\n  describe Show one agent's definition, methods, pipeline and recent iterations\n  history  Show recent applies and deletes\n  master   Start the cluster control plane\n  steer    Open the steering TUI\n")
 	os.Exit(1)
 }
 
@@ -53,6 +61,15 @@ func usage() {
 func cmdMaster(args []string) {
 	addr := cluster.DefaultAddr
 	statePath := cluster.DefaultStatePath()
+	force := false
+	webhookURL := ""
+	model := ""
+	promptVia := runtime.PromptStdin
+	tlsCert, tlsKey := "", ""
+	healthAddr := ""
+	applyFile := ""
+	unhealthyWindow := cluster.DefaultUnhealthyWindow
+	maxFailures := 0
 
 	// Parse flags
 	for i := 0; i < len(args); i++ {
@@ -71,15 +88,157 @@ func cmdMaster(args []string) {
 			}
 			statePath = args[i+1]
 			i++
+		case "--force":
+			force = true
+		case "--webhook":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--webhook requires an argument\n")
+				os.Exit(1)
+			}
+			webhookURL = args[i+1]
+			i++
+		case "--model":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--model requires an argument\n")
+				os.Exit(1)
+			}
+			model = args[i+1]
+			i++
+		case "--prompt-via":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--prompt-via requires an argument\n")
+				os.Exit(1)
+			}
+			mode, err := runtime.ParsePromptMode(args[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			promptVia = mode
+			i++
+		case "--tls-cert":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--tls-cert requires an argument\n")
+				os.Exit(1)
+			}
+			tlsCert = args[i+1]
+			i++
+		case "--tls-key":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--tls-key requires an argument\n")
+				os.Exit(1)
+			}
+			tlsKey = args[i+1]
+			i++
+		case "--health-addr":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--health-addr requires an argument\n")
+				os.Exit(1)
+			}
+			healthAddr = args[i+1]
+			i++
+		case "--apply":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--apply requires an argument\n")
+				os.Exit(1)
+			}
+			applyFile = args[i+1]
+			i++
+		case "--unhealthy-window":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--unhealthy-window requires an argument\n")
+				os.Exit(1)
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "--unhealthy-window must be a positive integer\n")
+				os.Exit(1)
+			}
+			unhealthyWindow = n
+			i++
+		case "--max-failures":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--max-failures requires an argument\n")
+				os.Exit(1)
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "--max-failures must be a non-negative integer\n")
+				os.Exit(1)
+			}
+			maxFailures = n
+			i++
 		}
 	}
 
+	if (tlsCert == "") != (tlsKey == "") {
+		fmt.Fprintf(os.Stderr, "--tls-cert and --tls-key must be given together\n")
+		os.Exit(1)
+	}
+
+	// Parse the --apply file up front: a master that can't load its
+	// definitions refuses to start rather than serving without them.
+	var startupDefs []cluster.AgentDef
+	if applyFile != "" {
+		defs, err := loadAgentDefs(applyFile, DefaultAgentPrefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: --apply %s: %v\n", applyFile, err)
+			os.Exit(1)
+		}
+		startupDefs = defs
+	}
+
+	// Refuse to share the state file with another master.
+	lock, err := cluster.LockState(statePath, force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
 	// Create store and load persisted state
 	store := cluster.NewStore()
 	cluster.LoadState(store, statePath)
 
 	// Create and start server with executor using the real claude CLI.
-	srv := cluster.NewServer(store, addr, runtime.CallClaudeStreaming)
+	// --model, if given, takes precedence over the MODEL env for all agents.
+	srv := cluster.NewServerWithUsage(store, addr, runtime.CallClaudeStreamingUsage(runtime.ClaudeConfig{Model: model, PromptVia: promptVia}))
+	srv.SetUnhealthyWindow(unhealthyWindow)
+	srv.Executor().SetMaxConsecutiveFailures(maxFailures)
+	srv.SetMasterConfig(cluster.MasterConfig{
+		StatePath:  statePath,
+		Model:      model,
+		PromptVia:  string(promptVia),
+		HealthAddr: healthAddr,
+	})
+	if webhookURL != "" {
+		srv.SetWebhook(cluster.NewWebhook(webhookURL))
+	}
+	if tlsCert != "" {
+		conf, err := cluster.ServerTLSConfig(tlsCert, tlsKey)
+		if err != nil {
+			lock.Release()
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		srv.SetTLSConfig(conf)
+	}
+	if healthAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(healthAddr, srv.HealthHandler()); err != nil {
+				log.Printf("health endpoint: %v", err)
+			}
+		}()
+	}
+	if applyFile != "" {
+		summary, err := srv.Apply(cluster.ApplyRequest{Agents: startupDefs}, "master --apply")
+		if err != nil {
+			lock.Release()
+			fmt.Fprintf(os.Stderr, "error: --apply %s: %v\n", applyFile, err)
+			os.Exit(1)
+		}
+		printApplySummary(summary)
+	}
 
 	// Handle shutdown signals
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -100,21 +259,32 @@ func cmdMaster(args []string) {
 	}()
 
 	if err := srv.ListenAndServe(); err != nil {
+		lock.Release()
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-// cmdApply parses a .p file, extracts agent- prefixed definitions,
-// compiles them to S-expressions, computes stable IDs, and sends
-// them to the master. Prints a summary of what changed.
+// DefaultAgentPrefix is the method-name prefix that marks agent definitions.
+const DefaultAgentPrefix = sexp.DefaultAgentPrefix
+
+// cmdApply parses a .p file, extracts prefixed agent definitions (agent-
+// by default), compiles them to S-expressions, computes stable IDs, and
+// sends them to the master. Prints a summary of what changed.
 func cmdApply(args []string) {
 	if len(args) < 1 {
-		fmt.Fprintf(os.Stderr, "usage: gcluster apply <file.p>\n")
+		fmt.Fprintf(os.Stderr, "usage: gcluster apply [--prefix <prefix>] [--only <name,...>] [--no-start] [--follow] [--check] <file.p>\n")
 		os.Exit(1)
 	}
 
 	addr := cluster.DefaultAddr
+	useTLS := false
+	caFile := ""
+	prefix := DefaultAgentPrefix
+	noStart := false
+	follow := false
+	check := false
+	var only []string
 	filename := ""
 
 	// Parse flags and positional args
@@ -127,6 +297,36 @@ func cmdApply(args []string) {
 			}
 			addr = args[i+1]
 			i++
+		case "--tls":
+			useTLS = true
+		case "--tls-ca":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--tls-ca requires an argument\n")
+				os.Exit(1)
+			}
+			caFile = args[i+1]
+			useTLS = true
+			i++
+		case "--prefix":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--prefix requires an argument\n")
+				os.Exit(1)
+			}
+			prefix = args[i+1]
+			i++
+		case "--no-start":
+			noStart = true
+		case "--follow":
+			follow = true
+		case "--check":
+			check = true
+		case "--only":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--only requires an argument\n")
+				os.Exit(1)
+			}
+			only = append(only, strings.Split(args[i+1], ",")...)
+			i++
 		default:
 			if filename == "" {
 				filename = args[i]
@@ -135,18 +335,195 @@ func cmdApply(args []string) {
 	}
 
 	if filename == "" {
-		fmt.Fprintf(os.Stderr, "usage: gcluster apply <file.p>\n")
+		fmt.Fprintf(os.Stderr, "usage: gcluster apply [--prefix <prefix>] [--only <name,...>] [--no-start] [--follow] [--check] <file.p>\n")
+		os.Exit(1)
+	}
+	if prefix == "" {
+		fmt.Fprintf(os.Stderr, "--prefix must not be empty\n")
 		os.Exit(1)
 	}
 
-	// Parse the .p file
-	nodes, err := parser.Parse(filename)
+	agentDefs, err := loadAgentDefs(filename, prefix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if len(only) > 0 {
+		if agentDefs, err = selectAgentDefs(agentDefs, only); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	tlsConf := clientTLS(useTLS, caFile)
+	if check {
+		live, err := fetchObjects(addr, tlsConf)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		drift := checkDrift(agentDefs, live, len(only) == 0)
+		printDrift(os.Stdout, drift)
+		if !drift.empty() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(agentDefs) == 0 {
+		fmt.Printf("0 agents applied (no %s definitions found)\n", prefix)
+		return
+	}
+
+	var resp cluster.ApplyResponse
+	if err := request(addr, tlsConf, cluster.MsgApplyRequest, cluster.ApplyRequest{Agents: agentDefs, NoStart: noStart}, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "error from master: %s\n", resp.Error)
+		os.Exit(1)
+	}
+
+	// Print summary
+	printApplySummary(resp.Summary)
+
+	if !follow {
+		return
+	}
+	sc, err := cluster.NewReadOnlySteerClient(addr, tlsConf)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "parse error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
+	defer sc.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	names := make([]string, len(agentDefs))
+	for i, def := range agentDefs {
+		names[i] = def.Name
+	}
+	followOutputs(ctx, sc, names, os.Stdout)
+}
+
+// followOutputs prints each finished iteration of the named agents as state
+// arrives from sc, until ctx is done. It is read-only: nothing is sent to
+// the master beyond the subscribe.
+func followOutputs(ctx context.Context, sc *cluster.SteerClient, agents []string, w io.Writer) {
+	seen := make(map[string]int, len(agents))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-sc.ErrCh:
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		case state := <-sc.StateCh:
+			for _, name := range agents {
+				run, ok := state.Runs[name]
+				if !ok {
+					continue
+				}
+				for _, it := range run.Iterations {
+					if it.Iteration <= seen[name] {
+						continue
+					}
+					seen[name] = it.Iteration
+					printIteration(w, name, it)
+				}
+			}
+		}
+	}
+}
+
+// printIteration writes one iteration's text output (or its error) under
+// an "[agent #n]" header.
+func printIteration(w io.Writer, name string, it cluster.IterationResult) {
+	fmt.Fprintf(w, "[%s #%d]\n", name, it.Iteration)
+	if it.Error != "" {
+		fmt.Fprintf(w, "error: %s\n", it.Error)
+		return
+	}
+	for _, msg := range it.Messages {
+		if msg.Type == "text" {
+			fmt.Fprintln(w, msg.Content)
+		}
+	}
+}
+
+// selectAgentDefs keeps only the named agents from defs, in file order, for
+// `apply --only`. Names are agent names (prefix already stripped). It fails
+// if any name isn't defined in the file.
+func selectAgentDefs(defs []cluster.AgentDef, names []string) ([]cluster.AgentDef, error) {
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			want[name] = true
+		}
+	}
+	var out []cluster.AgentDef
+	for _, def := range defs {
+		if want[def.Name] {
+			out = append(out, def)
+			delete(want, def.Name)
+		}
+	}
+	if len(want) > 0 {
+		missing := make([]string, 0, len(want))
+		for name := range want {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("--only: no agent named %s in file", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// loadAgentDefs parses a .p file and its imports, builds a registry with
+// stdlib and all methods (agents reference non-agent methods), and compiles
+// every method whose name starts with prefix into an AgentDef. The agent
+// name is the method name with the prefix stripped.
+func loadAgentDefs(filename, prefix string) ([]cluster.AgentDef, error) {
+	nodes, err := parser.Parse(filename)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
+	// Pull `output: <path>`, `labels: k=v, ...`, `schedule: <cron>`,
+	// `max-iterations: <n>` and `max-map-concurrency: <n>` directives out
+	// of pipeline agent bodies before anything else sees them, so the
+	// remaining body parses as a normal pipeline. A plain agent's body is
+	// its prompt and is sent verbatim, so lines in it that look like
+	// directives are left alone.
+	sinks := make(map[string]string)
+	labels := make(map[string]map[string]string)
+	schedules := make(map[string]string)
+	maxIters := make(map[string]int)
+	mapConcurrency := make(map[string]int)
+	for i, node := range nodes {
+		if node.Type == parser.NodeMethodDef && strings.HasPrefix(node.Name, prefix) {
+			var rawLabels, schedule, rawMaxIters, rawMapConcurrency string
+			body, sink := splitDirective(node.Body, "output")
+			body, rawLabels = splitDirective(body, "labels")
+			body, schedule = splitDirective(body, "schedule")
+			body, rawMaxIters = splitDirective(body, "max-iterations")
+			body, rawMapConcurrency = splitDirective(body, "max-map-concurrency")
+			if !pipeline.IsPipeline(body) {
+				continue
+			}
+			nodes[i].Body, sinks[node.Name], schedules[node.Name] = body, sink, schedule
+			if labels[node.Name], err = parseLabels(rawLabels); err != nil {
+				return nil, fmt.Errorf("error: agent %q: %w", node.Name, err)
+			}
+			if maxIters[node.Name], err = parseCount("max-iterations", rawMaxIters); err != nil {
+				return nil, fmt.Errorf("error: agent %q: %w", node.Name, err)
+			}
+			if mapConcurrency[node.Name], err = parseCount("max-map-concurrency", rawMapConcurrency); err != nil {
+				return nil, fmt.Errorf("error: agent %q: %w", node.Name, err)
+			}
+		}
+	}
 
-	// Build registry with stdlib and all methods (agents reference non-agent methods)
 	reg := registry.New()
 	loadStdlib(reg, filename)
 
@@ -159,8 +536,7 @@ func cmdApply(args []string) {
 			importPath := resolveImport(node.ImportPath, fileDir)
 			importNodes, err := parser.Parse(importPath)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "import error (%s): %v\n", node.ImportPath, err)
-				os.Exit(1)
+				return nil, fmt.Errorf("import error (%s): %w", node.ImportPath, err)
 			}
 			for _, n := range importNodes {
 				if n.Type == parser.NodeMethodDef {
@@ -170,25 +546,54 @@ func cmdApply(args []string) {
 		}
 	}
 
-	// Extract agent- prefixed definitions, compile to AgentDefs,
+	// Extract prefixed definitions, compile to AgentDefs,
 	// and resolve method bodies for executor use.
 	var agentDefs []cluster.AgentDef
 	for _, node := range nodes {
 		if node.Type != parser.NodeMethodDef {
 			continue
 		}
-		if !strings.HasPrefix(node.Name, "agent-") {
+		if !strings.HasPrefix(node.Name, prefix) {
 			continue
 		}
 
 		// Emit S-expression for this agent definition
-		sexpr := sexp.EmitProgram(nodes, reg, node.Name)
+		sexpr := sexp.EmitProgram(nodes, reg, node.Name, prefix)
 		if sexpr == "" {
-			fmt.Fprintf(os.Stderr, "error: could not compile agent %q to S-expression\n", node.Name)
-			os.Exit(1)
+			return nil, fmt.Errorf("error: could not compile agent %q to S-expression", node.Name)
 		}
 
-		agentName := strings.TrimPrefix(node.Name, "agent-")
+		// The sink is part of the definition, so changing it is a new revision.
+		sink := sinks[node.Name]
+		if sink != "" {
+			if err := cluster.ValidateOutputSink(sink); err != nil {
+				return nil, fmt.Errorf("error: agent %q: %w", node.Name, err)
+			}
+			sexpr += fmt.Sprintf("(output %q)\n", sink)
+		}
+		schedule := schedules[node.Name]
+		if schedule != "" {
+			if _, err := cluster.ParseSchedule(schedule); err != nil {
+				return nil, fmt.Errorf("error: agent %q: %w", node.Name, err)
+			}
+			sexpr += fmt.Sprintf("(schedule %q)\n", schedule)
+		}
+
+		pdef := buildPipelineDef(node)
+		if n := maxIters[node.Name]; n > 0 {
+			if pdef == nil || !pdef.SetLoopMaxIterations(n) {
+				return nil, fmt.Errorf("error: agent %q: max-iterations needs a loop step", node.Name)
+			}
+			sexpr += fmt.Sprintf("(max-iterations %d)\n", n)
+		}
+		if n := mapConcurrency[node.Name]; n > 0 {
+			if pdef == nil || !pdef.SetMapConcurrency(n) {
+				return nil, fmt.Errorf("error: agent %q: max-map-concurrency needs a map step", node.Name)
+			}
+			sexpr += fmt.Sprintf("(max-map-concurrency %d)\n", n)
+		}
+
+		agentName := strings.TrimPrefix(node.Name, prefix)
 		stableID := sexp.StableID(sexpr)
 
 		// Resolve method bodies referenced by the agent's pipeline.
@@ -197,99 +602,488 @@ func cmdApply(args []string) {
 		methods := resolveAgentMethods(node, reg)
 
 		agentDefs = append(agentDefs, cluster.AgentDef{
-			Name:       agentName,
-			Definition: sexpr,
-			ID:         stableID,
-			Methods:    methods,
-			Pipeline:   buildPipelineDef(node),
+			Name:          agentName,
+			Definition:    sexpr,
+			ID:            stableID,
+			Methods:       methods,
+			Pipeline:   pdef,
+			OutputSink: sink,
+			Labels:     labels[node.Name],
+			Schedule:   schedule,
 		})
 	}
+	return agentDefs, nil
+}
 
-	if len(agentDefs) == 0 {
-		fmt.Println("0 agents applied (no agent- definitions found)")
+// splitDirective removes `<name>: <value>` lines from an agent body and
+// returns the remaining body and the declared value (the last one wins).
+func splitDirective(body, name string) (string, string) {
+	var kept []string
+	value := ""
+	for _, line := range strings.Split(body, "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), name+":"); ok {
+			value = strings.TrimSpace(rest)
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if value == "" {
+		return body, ""
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n")), value
+}
+
+// parseCount parses the value of a numeric directive such as
+// `max-iterations:`, which must be a positive integer. An empty value
+// (directive absent) is 0.
+func parseCount(name, s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s: %q is not a positive integer", name, s)
+	}
+	return n, nil
+}
+
+// parseLabels parses a `labels:` directive value: comma- or space-separated
+// key=value pairs, e.g. "team=backend, env=staging".
+func parseLabels(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("label %q is not key=value", field)
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+func printApplySummary(s cluster.ApplySummary) {
+	total := len(s.Created) + len(s.Updated) + len(s.Unchanged)
+	fmt.Printf("%d agent(s) applied: %d created, %d updated, %d unchanged\n",
+		total, len(s.Created), len(s.Updated), len(s.Unchanged))
+
+	for _, name := range s.Created {
+		fmt.Printf("  + %s (created, rev %s)\n", name, shortID(s.Revisions[name]))
+	}
+	for _, name := range s.Updated {
+		fmt.Printf("  ~ %s (updated, rev %s)\n", name, shortID(s.Revisions[name]))
+	}
+	for _, name := range s.Unchanged {
+		fmt.Printf("  = %s (unchanged)\n", name)
+	}
+}
+
+// applyDrift is what applying a file would change in the cluster.
+type applyDrift struct {
+	Created    []string // in the file, not in the cluster
+	Updated    []string // definition differs, so apply would add a revision
+	Relabelled []string // same definition, different labels
+	Missing    []string // in the cluster, not in the file
+}
+
+func (d applyDrift) empty() bool {
+	return len(d.Created)+len(d.Updated)+len(d.Relabelled)+len(d.Missing) == 0
+}
+
+// checkDrift compares defs with the cluster's live objects the way apply
+// would: definitions by stable ID, then labels. With withMissing, cluster
+// agents absent from defs count as drift too.
+func checkDrift(defs []cluster.AgentDef, live []cluster.ClusterObject, withMissing bool) applyDrift {
+	byName := make(map[string]cluster.ClusterObject, len(live))
+	for _, obj := range live {
+		byName[obj.Name] = obj
+	}
+	var d applyDrift
+	inFile := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		inFile[def.Name] = true
+		obj, ok := byName[def.Name]
+		switch {
+		case !ok:
+			d.Created = append(d.Created, def.Name)
+		case obj.ID != def.ID:
+			d.Updated = append(d.Updated, def.Name)
+		case !maps.Equal(obj.Labels, def.Labels):
+			d.Relabelled = append(d.Relabelled, def.Name)
+		}
+	}
+	if withMissing {
+		for _, obj := range live {
+			if !inFile[obj.Name] {
+				d.Missing = append(d.Missing, obj.Name)
+			}
+		}
+		sort.Strings(d.Missing)
+	}
+	return d
+}
+
+// printDrift writes a check's result in the style of the apply summary.
+func printDrift(w io.Writer, d applyDrift) {
+	if d.empty() {
+		fmt.Fprintln(w, "in sync: applying would change nothing")
 		return
 	}
+	fmt.Fprintln(w, "drift: applying would change the cluster")
+	for _, name := range d.Created {
+		fmt.Fprintf(w, "  + %s (would be created)\n", name)
+	}
+	for _, name := range d.Updated {
+		fmt.Fprintf(w, "  ~ %s (definition differs)\n", name)
+	}
+	for _, name := range d.Relabelled {
+		fmt.Fprintf(w, "  ~ %s (labels differ)\n", name)
+	}
+	for _, name := range d.Missing {
+		fmt.Fprintf(w, "  - %s (in cluster, not in file)\n", name)
+	}
+}
+
+// fetchObjects returns the master's current cluster objects, from the
+// state a read-only steer subscribe is answered with.
+func fetchObjects(addr string, tlsConf *tls.Config) ([]cluster.ClusterObject, error) {
+	var state cluster.SteerStatePayload
+	if err := request(addr, tlsConf, cluster.MsgSteerSubscribe, cluster.SteerSubscribeRequest{ReadOnly: true}, &state); err != nil {
+		return nil, err
+	}
+	return state.Objects, nil
+}
 
-	// Connect to master and send apply request
-	conn, err := net.Dial("tcp", addr)
+// clientTLS returns the TLS config for the --tls and --tls-ca flags, or
+// nil for plain TCP. It exits if the CA file can't be loaded.
+func clientTLS(useTLS bool, caFile string) *tls.Config {
+	if !useTLS {
+		return nil
+	}
+	conf, err := cluster.ClientTLSConfig(caFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "cannot connect to master at %s — is `gcluster master` running?\n", addr)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
+	return conf
+}
+
+// request sends a single request envelope to the master and decodes the
+// first reply's payload into dst. Used by the one-shot request/response
+// commands (apply, describe, history).
+func request(addr string, tlsConf *tls.Config, msgType cluster.MessageType, payload interface{}, dst interface{}) error {
+	conn, err := cluster.Dial(addr, tlsConf)
+	if err != nil {
+		return fmt.Errorf("cannot connect to master at %s — is `gcluster master` running?", addr)
+	}
 	defer conn.Close()
 
-	// Send apply_request
-	env, err := cluster.NewEnvelope(cluster.MsgApplyRequest, cluster.ApplyRequest{Agents: agentDefs})
+	env, err := cluster.NewEnvelope(msgType, payload)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error: %w", err)
 	}
 	data, err := json.Marshal(env)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error: %w", err)
 	}
 	data = append(data, '\n')
 	if _, err := conn.Write(data); err != nil {
-		fmt.Fprintf(os.Stderr, "error sending to master: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error sending to master: %w", err)
 	}
 
-	// Read apply_response
 	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	scanner.Buffer(make([]byte, 0, 4*1024*1024), 4*1024*1024)
 	if !scanner.Scan() {
-		fmt.Fprintf(os.Stderr, "error: no response from master\n")
-		os.Exit(1)
+		return fmt.Errorf("error: no response from master")
 	}
 
 	var respEnv cluster.Envelope
 	if err := json.Unmarshal(scanner.Bytes(), &respEnv); err != nil {
-		fmt.Fprintf(os.Stderr, "error: malformed response: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error: malformed response: %w", err)
 	}
+	if err := respEnv.DecodePayload(dst); err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+	return nil
+}
 
-	var resp cluster.ApplyResponse
-	if err := respEnv.DecodePayload(&resp); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+// cmdDescribe prints everything the master knows about one agent: its
+// definition and revisions, method bodies, pipeline steps and a summary of
+// recent iterations. The non-interactive counterpart to the steer TUI.
+func cmdDescribe(args []string) {
+	addr := cluster.DefaultAddr
+	useTLS := false
+	caFile := ""
+	name := ""
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--addr":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--addr requires an argument\n")
+				os.Exit(1)
+			}
+			addr = args[i+1]
+			i++
+		case "--tls":
+			useTLS = true
+		case "--tls-ca":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--tls-ca requires an argument\n")
+				os.Exit(1)
+			}
+			caFile = args[i+1]
+			useTLS = true
+			i++
+		default:
+			if name == "" {
+				name = args[i]
+			}
+		}
+	}
+
+	if name == "" {
+		fmt.Fprintf(os.Stderr, "usage: gcluster describe <agent>\n")
 		os.Exit(1)
 	}
 
+	var resp cluster.DescribeResponse
+	if err := request(addr, clientTLS(useTLS, caFile), cluster.MsgDescribeRequest, cluster.DescribeRequest{AgentName: name}, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 	if resp.Error != "" {
 		fmt.Fprintf(os.Stderr, "error from master: %s\n", resp.Error)
 		os.Exit(1)
 	}
 
-	// Print summary
-	printApplySummary(resp.Summary)
+	printDescribe(resp)
 }
 
-func printApplySummary(s cluster.ApplySummary) {
-	total := len(s.Created) + len(s.Updated) + len(s.Unchanged)
-	fmt.Printf("%d agent(s) applied: %d created, %d updated, %d unchanged\n",
-		total, len(s.Created), len(s.Updated), len(s.Unchanged))
+func printDescribe(d cluster.DescribeResponse) {
+	obj := d.Object
+	fmt.Printf("Name:      %s\n", obj.Name)
+	fmt.Printf("State:     %s\n", obj.State)
+	fmt.Printf("Revision:  %s\n", shortID(obj.CurrentRevision))
+	if len(obj.Labels) > 0 {
+		fmt.Printf("Labels:    %s\n", cluster.FormatLabels(obj.Labels))
+	}
 
-	for _, name := range s.Created {
-		fmt.Printf("  + %s (created)\n", name)
+	fmt.Printf("\nDefinition:\n%s\n", indentBlock(obj.Definition))
+
+	fmt.Printf("\nRevisions:\n")
+	for _, rev := range obj.Revisions {
+		fmt.Printf("  %s  %s\n", shortID(rev.ID), rev.Timestamp.Format("2006-01-02 15:04:05"))
 	}
-	for _, name := range s.Updated {
-		fmt.Printf("  ~ %s (updated)\n", name)
+
+	if d.Pipeline != nil && len(d.Pipeline.Steps) > 0 {
+		fmt.Printf("\nPipeline:\n")
+		for i, step := range d.Pipeline.Steps {
+			var method string
+			switch step.Kind {
+			case cluster.StepKindSimple:
+				method = step.Method
+			case cluster.StepKindMap:
+				method = step.MapMethod
+			case cluster.StepKindReduce:
+				method = step.ReduceMethod
+			case cluster.StepKindLoop:
+				method = step.LoopMethod
+			}
+			fmt.Printf("  %d. %s %s(%s)\n", i+1, step.Label, step.Kind, method)
+		}
 	}
-	for _, name := range s.Unchanged {
-		fmt.Printf("  = %s (unchanged)\n", name)
+
+	if len(d.Methods) > 0 {
+		names := make([]string, 0, len(d.Methods))
+		for name := range d.Methods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("\nMethods:\n")
+		for _, name := range names {
+			fmt.Printf("  %s:\n%s\n", name, indentBlock(d.Methods[name]))
+		}
+	}
+
+	if d.Run != nil {
+		fmt.Printf("\nRecent iterations:\n")
+		if d.Run.LiveIter != nil {
+			fmt.Printf("  #%d  running since %s\n", d.Run.LiveIter.Iteration, d.Run.LiveIter.StartedAt.Format("15:04:05"))
+		}
+		for i := len(d.Run.Iterations) - 1; i >= 0; i-- {
+			ir := d.Run.Iterations[i]
+			status := "ok"
+			if ir.Error != "" {
+				status = "error: " + ir.Error
+			}
+			fmt.Printf("  #%d  %.1fs  %s\n", ir.Iteration, ir.FinishedAt.Sub(ir.StartedAt).Seconds(), status)
+		}
+		if d.Run.LiveIter == nil && len(d.Run.Iterations) == 0 {
+			fmt.Printf("  (none yet)\n")
+		}
 	}
 }
 
+// cmdHistory prints the master's recent apply and delete events, oldest
+// first: when each happened, which client sent it and what changed.
+func cmdHistory(args []string) {
+	addr := cluster.DefaultAddr
+	useTLS := false
+	caFile := ""
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--addr":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--addr requires an argument\n")
+				os.Exit(1)
+			}
+			addr = args[i+1]
+			i++
+		case "--tls":
+			useTLS = true
+		case "--tls-ca":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--tls-ca requires an argument\n")
+				os.Exit(1)
+			}
+			caFile = args[i+1]
+			useTLS = true
+			i++
+		}
+	}
+
+	var resp cluster.HistoryResponse
+	if err := request(addr, clientTLS(useTLS, caFile), cluster.MsgHistoryRequest, cluster.HistoryRequest{}, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if len(resp.Events) == 0 {
+		fmt.Println("no applies yet")
+		return
+	}
+	for _, ev := range resp.Events {
+		fmt.Printf("%s  %s\n", ev.Timestamp.Format("2006-01-02 15:04:05"), ev.Client)
+		for _, name := range ev.Created {
+			fmt.Printf("  + %s (created)\n", name)
+		}
+		for _, name := range ev.Updated {
+			fmt.Printf("  ~ %s (updated)\n", name)
+		}
+		for _, name := range ev.Deleted {
+			fmt.Printf("  - %s (deleted)\n", name)
+		}
+		if len(ev.Created)+len(ev.Updated)+len(ev.Deleted) == 0 {
+			fmt.Printf("  (no changes)\n")
+		}
+	}
+}
+
+// cmdConfig prints the effective configuration of the running master, with
+// secrets redacted.
+func cmdConfig(args []string) {
+	addr := cluster.DefaultAddr
+	useTLS := false
+	caFile := ""
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--addr":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--addr requires an argument\n")
+				os.Exit(1)
+			}
+			addr = args[i+1]
+			i++
+		case "--tls":
+			useTLS = true
+		case "--tls-ca":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--tls-ca requires an argument\n")
+				os.Exit(1)
+			}
+			caFile = args[i+1]
+			useTLS = true
+			i++
+		}
+	}
+
+	var resp cluster.ConfigResponse
+	if err := request(addr, clientTLS(useTLS, caFile), cluster.MsgConfigRequest, cluster.ConfigRequest{}, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(formatConfig(resp.Config))
+}
+
+// formatConfig renders a master's configuration one setting per line.
+// Unset optional settings are shown as "-".
+func formatConfig(c cluster.MasterConfig) string {
+	orDash := func(v string) string {
+		if v == "" {
+			return "-"
+		}
+		return v
+	}
+	limit := func(n int) string {
+		if n == 0 {
+			return "none"
+		}
+		return strconv.Itoa(n)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "addr:                 %s\n", c.Addr)
+	fmt.Fprintf(&sb, "tls:                  %t\n", c.TLS)
+	fmt.Fprintf(&sb, "state:                %s\n", orDash(c.StatePath))
+	fmt.Fprintf(&sb, "model:                %s\n", orDash(c.Model))
+	fmt.Fprintf(&sb, "prompt-via:           %s\n", orDash(c.PromptVia))
+	fmt.Fprintf(&sb, "health-addr:          %s\n", orDash(c.HealthAddr))
+	fmt.Fprintf(&sb, "webhook:              %s\n", orDash(c.Webhook))
+	fmt.Fprintf(&sb, "unhealthy-window:     %d\n", c.UnhealthyWindow)
+	fmt.Fprintf(&sb, "max-failures:         %s\n", limit(c.MaxFailures))
+	fmt.Fprintf(&sb, "push-interval:        %v\n", c.PushInterval)
+	fmt.Fprintf(&sb, "max-definition-bytes: %s\n", limit(c.Limits.MaxDefinitionBytes))
+	fmt.Fprintf(&sb, "max-pipeline-steps:   %s\n", limit(c.Limits.MaxPipelineSteps))
+	fmt.Fprintf(&sb, "max-methods:          %s\n", limit(c.Limits.MaxMethods))
+	return sb.String()
+}
+
+// shortID returns the first 8 characters of a revision ID.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// indentBlock indents every line of s by four spaces.
+func indentBlock(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = "    " + l
+	}
+	return strings.Join(lines, "\n")
+}
+
 // cmdSteer opens the steering TUI connected to the master.
 // It subscribes for state updates and presents a two-pane view:
 // tree sidebar showing agents/loops/iterations, and a detail view
 // for the currently selected node.
 func cmdSteer(args []string) {
 	addr := cluster.DefaultAddr
+	useTLS := false
+	caFile := ""
+	readOnly := false
 
 	// Parse flags
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
+		case "--read-only":
+			readOnly = true
 		case "--addr":
 			if i+1 >= len(args) {
 				fmt.Fprintf(os.Stderr, "--addr requires an argument\n")
@@ -297,11 +1091,25 @@ func cmdSteer(args []string) {
 			}
 			addr = args[i+1]
 			i++
+		case "--tls":
+			useTLS = true
+		case "--tls-ca":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--tls-ca requires an argument\n")
+				os.Exit(1)
+			}
+			caFile = args[i+1]
+			useTLS = true
+			i++
 		}
 	}
 
 	// Connect to master
-	client, err := cluster.NewSteerClient(addr)
+	connect := cluster.NewSteerClientTLS
+	if readOnly {
+		connect = cluster.NewReadOnlySteerClient
+	}
+	client, err := connect(addr, clientTLS(useTLS, caFile))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
@@ -377,16 +1185,21 @@ func resolveAgentMethods(node parser.Node, reg *registry.Registry) map[string]st
 	}
 
 	for _, step := range p.Steps {
-		var methodName string
+		var names []string
 		switch step.Kind {
 		case pipeline.StepSimple:
-			methodName = step.Method
+			names = []string{step.Method}
 		case pipeline.StepLoop:
-			methodName = step.LoopMethod
+			names = []string{step.LoopMethod, step.WarmupMethod}
 		case pipeline.StepMap:
-			methodName = step.MapMethod
+			names = []string{step.MapMethod}
+		case pipeline.StepReduce:
+			names = []string{step.ReduceMethod}
 		}
-		if methodName != "" {
+		for _, methodName := range names {
+			if methodName == "" {
+				continue
+			}
 			m := reg.Get(methodName)
 			if m != nil {
 				methods[methodName] = m.Body
@@ -420,10 +1233,14 @@ func buildPipelineDef(node parser.Node) *cluster.PipelineDef {
 		case pipeline.StepLoop:
 			ps.Kind = cluster.StepKindLoop
 			ps.LoopMethod = step.LoopMethod
+			ps.WarmupMethod = step.WarmupMethod
 		case pipeline.StepMap:
 			ps.Kind = cluster.StepKindMap
 			ps.MapMethod = step.MapMethod
 			ps.MapRef = step.MapRef
+		case pipeline.StepReduce:
+			ps.Kind = cluster.StepKindReduce
+			ps.ReduceMethod = step.ReduceMethod
 		}
 		def.Steps = append(def.Steps, ps)
 	}
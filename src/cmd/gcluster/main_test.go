@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"p2p/cluster"
+)
+
+// writeP writes a .p source file into a temp dir and returns its path.
+func writeP(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agents.p")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const mixedPrefixSource = `build:
+	Read BACKLOG.md and build one item.
+
+agent-builder:
+	loop(build)
+
+bot-helper:
+	loop(build)
+`
+
+func TestLoadAgentDefsCustomPrefix(t *testing.T) {
+	path := writeP(t, mixedPrefixSource)
+
+	defs, err := loadAgentDefs(path, "bot-")
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	if len(defs) != 1 {
+		t.Fatalf("expected 1 agent with prefix bot-, got %d", len(defs))
+	}
+	if defs[0].Name != "helper" {
+		t.Errorf("expected agent name %q, got %q", "helper", defs[0].Name)
+	}
+	if !strings.Contains(defs[0].Definition, `(defagent "helper"`) {
+		t.Errorf("expected a defagent form named without the prefix, got:\n%s", defs[0].Definition)
+	}
+	if defs[0].Methods["build"] == "" {
+		t.Errorf("expected non-agent method build to be resolved, got %v", defs[0].Methods)
+	}
+
+	defs, err = loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	if len(defs) != 1 || defs[0].Name != "builder" {
+		t.Fatalf("expected only builder with default prefix, got %+v", defs)
+	}
+}
+
+func TestLoadAgentDefsOutputSink(t *testing.T) {
+	path := writeP(t, `build:
+	Read BACKLOG.md and build one item.
+
+agent-builder:
+	loop(build)
+	output: logs/builder.log
+`)
+
+	defs, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	if len(defs) != 1 {
+		t.Fatalf("expected 1 agent, got %d", len(defs))
+	}
+	def := defs[0]
+	if def.OutputSink != "logs/builder.log" {
+		t.Errorf("expected output sink logs/builder.log, got %q", def.OutputSink)
+	}
+	if def.Pipeline == nil || len(def.Pipeline.Steps) != 1 || def.Pipeline.Steps[0].LoopMethod != "build" {
+		t.Errorf("output directive should not affect the pipeline, got %+v", def.Pipeline)
+	}
+	if !strings.Contains(def.Definition, `(output "logs/builder.log")`) {
+		t.Errorf("definition should record the sink, got %q", def.Definition)
+	}
+
+	escape := writeP(t, "agent-builder:\n\tloop(build)\n\toutput: ../../etc/passwd\n")
+	if _, err := loadAgentDefs(escape, DefaultAgentPrefix); err == nil {
+		t.Fatal("expected an error for a sink outside the working directory")
+	}
+}
+
+func TestLoadAgentDefsLabels(t *testing.T) {
+	path := writeP(t, `build:
+	Read BACKLOG.md and build one item.
+
+agent-builder:
+	loop(build)
+	labels: team=backend, env=staging
+`)
+
+	defs, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	def := defs[0]
+	if len(def.Labels) != 2 || def.Labels["team"] != "backend" || def.Labels["env"] != "staging" {
+		t.Errorf("unexpected labels: %v", def.Labels)
+	}
+	if strings.Contains(def.Definition, "labels") {
+		t.Errorf("labels should not be part of the definition, got %q", def.Definition)
+	}
+
+	bad := writeP(t, "agent-builder:\n\tloop(build)\n\tlabels: team\n")
+	if _, err := loadAgentDefs(bad, DefaultAgentPrefix); err == nil {
+		t.Fatal("expected an error for a label without a value")
+	}
+}
+
+func TestLoadAgentDefsSchedule(t *testing.T) {
+	path := writeP(t, `report:
+	Summarise yesterday's commits.
+
+agent-nightly:
+	commits -> summary (report)
+	schedule: 0 2 * * *
+`)
+
+	defs, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	def := defs[0]
+	if def.Schedule != "0 2 * * *" {
+		t.Errorf("expected schedule 0 2 * * *, got %q", def.Schedule)
+	}
+	if def.Pipeline == nil || len(def.Pipeline.Steps) != 1 || def.Pipeline.Steps[0].Kind != cluster.StepKindSimple {
+		t.Errorf("schedule directive should not affect the pipeline, got %+v", def.Pipeline)
+	}
+	if !strings.Contains(def.Definition, `(schedule "0 2 * * *")`) {
+		t.Errorf("definition should record the schedule, got %q", def.Definition)
+	}
+
+	bad := writeP(t, "agent-nightly:\n\tcommits -> summary (report)\n\tschedule: 0 25 * * *\n")
+	if _, err := loadAgentDefs(bad, DefaultAgentPrefix); err == nil {
+		t.Fatal("expected an error for an invalid cron expression")
+	}
+}
+
+func TestLoadAgentDefsPlainPromptKeepsDirectiveLines(t *testing.T) {
+	prompt := "Summarise the build log.\noutput: foo\nlabels: team\nmax-iterations: 5"
+	path := writeP(t, "agent-plain:\n\t"+strings.ReplaceAll(prompt, "\n", "\n\t")+"\n")
+
+	defs, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	def := defs[0]
+	if got := def.Methods["agent-plain"]; got != prompt {
+		t.Errorf("a plain prompt should round-trip unchanged, got %q", got)
+	}
+	if def.OutputSink != "" || def.Labels != nil || def.MaxIterations != 0 {
+		t.Errorf("prose in a plain prompt should not become config, got %+v", def)
+	}
+}
+
+func TestLoadAgentDefsMaxIterations(t *testing.T) {
+	path := writeP(t, `build:
+	Build one item.
+
+agent-piped:
+	idea -> spec -> loop(build)
+	max-iterations: 3
+`)
+
+	defs, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	piped := defs[0]
+	if !strings.Contains(piped.Definition, "(max-iterations 3)") {
+		t.Errorf("definition should record the cap, got %q", piped.Definition)
+	}
+	if piped.MaxIterations != 0 {
+		t.Errorf("a pipeline agent's cap belongs on its loop step, got %d on the agent", piped.MaxIterations)
+	}
+	if loop := piped.Pipeline.Steps[len(piped.Pipeline.Steps)-1]; loop.Kind != cluster.StepKindLoop || loop.MaxIterations != 3 {
+		t.Errorf("expected the loop step capped at 3, got %+v", loop)
+	}
+
+	for _, src := range []string{
+		"agent-a:\n\tidea -> spec -> plan\n\tmax-iterations: 3\n",
+		"agent-a:\n\tloop(build)\n\tmax-iterations: 0\n",
+		"agent-a:\n\tloop(build)\n\tmax-iterations: lots\n",
+	} {
+		if _, err := loadAgentDefs(writeP(t, src), DefaultAgentPrefix); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}
+
+func TestLoadAgentDefsMapConcurrency(t *testing.T) {
+	path := writeP(t, `agent-fanout:
+	topic -> ideas -> map(ideas, expand) -> loop(build)
+	max-map-concurrency: 4
+`)
+
+	defs, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	def := defs[0]
+	if step := def.Pipeline.Steps[1]; step.Kind != cluster.StepKindMap || step.MaxMapConcurrency != 4 {
+		t.Errorf("expected the map step bounded to 4, got %+v", step)
+	}
+	if !strings.Contains(def.Definition, "(max-map-concurrency 4)") {
+		t.Errorf("definition should record the bound, got %q", def.Definition)
+	}
+
+	for _, src := range []string{
+		"agent-a:\n\tloop(build)\n\tmax-map-concurrency: 4\n",
+		"agent-a:\n\ttopic -> map(ideas, expand)\n\tmax-map-concurrency: -1\n",
+	} {
+		if _, err := loadAgentDefs(writeP(t, src), DefaultAgentPrefix); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}
+
+// TestMasterStartupApply mirrors `gcluster master --apply`: the file's agents
+// are in the store before the server accepts connections.
+func TestMasterStartupApply(t *testing.T) {
+	defs, err := loadAgentDefs(writeP(t, mixedPrefixSource), DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	store := cluster.NewStore()
+	srv := cluster.NewServer(store, "127.0.0.1:0")
+	summary, err := srv.Apply(cluster.ApplyRequest{Agents: defs}, "master --apply")
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(summary.Created) != 1 || summary.Created[0] != "builder" {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if store.GetAgent("builder") == nil {
+		t.Fatal("builder not in store after startup apply")
+	}
+}
+
+// cancelWriter collects followOutputs' output and cancels once it sees an
+// iteration's output.
+type cancelWriter struct {
+	mu     sync.Mutex
+	buf    strings.Builder
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	if strings.Contains(w.buf.String(), "built one item") {
+		w.cancel()
+	}
+	return len(p), nil
+}
+
+// TestApplyFollow applies an agent to a master backed by a fake claude and
+// follows it until an iteration's output arrives.
+func TestApplyFollow(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	fake := func(ctx context.Context, prompt string, onMessage func(cluster.ConvoMessage)) (string, error) {
+		if onMessage != nil {
+			onMessage(cluster.ConvoMessage{ID: "msg-1", Type: "text", Content: "built one item"})
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+		return "built one item", nil
+	}
+	srv := cluster.NewServer(cluster.NewStore(), addr, fake)
+	go srv.ListenAndServe()
+	defer srv.Stop()
+
+	defs, err := loadAgentDefs(writeP(t, mixedPrefixSource), DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	var resp cluster.ApplyResponse
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		err = request(addr, nil, cluster.MsgApplyRequest, cluster.ApplyRequest{Agents: defs}, &resp)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || resp.Error != "" {
+		t.Fatalf("apply: %v %s", err, resp.Error)
+	}
+
+	sc, err := cluster.NewSteerClient(addr)
+	if err != nil {
+		t.Fatalf("NewSteerClient: %v", err)
+	}
+	defer sc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	w := &cancelWriter{cancel: cancel}
+	followOutputs(ctx, sc, []string{"builder"}, w)
+
+	w.mu.Lock()
+	out := w.buf.String()
+	w.mu.Unlock()
+	if !strings.Contains(out, "[builder #") || !strings.Contains(out, "built one item") {
+		t.Fatalf("expected an iteration's output, got %q", out)
+	}
+}
+
+// TestApplyCheck mirrors `gcluster apply --check`: no drift after the file
+// is applied, and drift once the file changes.
+func TestApplyCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	srv := cluster.NewServer(cluster.NewStore(), addr)
+	go srv.ListenAndServe()
+	defer srv.Stop()
+
+	path := writeP(t, mixedPrefixSource)
+	defs, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	var live []cluster.ClusterObject
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		live, err = fetchObjects(addr, nil)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("fetchObjects: %v", err)
+	}
+	if d := checkDrift(defs, live, true); len(d.Created) != 1 || d.Created[0] != "builder" {
+		t.Fatalf("expected builder to be reported as created before apply, got %+v", d)
+	}
+
+	for i := 0; i < 2; i++ {
+		var resp cluster.ApplyResponse
+		if err := request(addr, nil, cluster.MsgApplyRequest, cluster.ApplyRequest{Agents: defs, NoStart: true}, &resp); err != nil || resp.Error != "" {
+			t.Fatalf("apply: %v %s", err, resp.Error)
+		}
+	}
+	if live, err = fetchObjects(addr, nil); err != nil {
+		t.Fatalf("fetchObjects: %v", err)
+	}
+	if d := checkDrift(defs, live, true); !d.empty() {
+		t.Fatalf("expected no drift after applying, got %+v", d)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Replace(mixedPrefixSource, "agent-builder:\n\tloop(build)", "agent-builder:\n\tloop(build)\n\tlabels: team=infra", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	relabelled, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	if d := checkDrift(relabelled, live, true); len(d.Relabelled) != 1 {
+		t.Errorf("expected builder's labels to differ, got %+v", d)
+	}
+
+	changed, err := loadAgentDefs(writeP(t, "build:\n\tBuild one item.\n\nagent-builder:\n\tloop(build)\n\toutput: logs/builder.log\n"), DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	d := checkDrift(changed, live, true)
+	if d.empty() || len(d.Updated) != 1 {
+		t.Errorf("expected builder's definition to differ, got %+v", d)
+	}
+	if d := checkDrift(nil, live, true); len(d.Missing) != 1 || d.Missing[0] != "builder" {
+		t.Errorf("expected builder to be missing from an empty file, got %+v", d)
+	}
+	if d := checkDrift(nil, live, false); !d.empty() {
+		t.Errorf("missing agents should be ignored with --only, got %+v", d)
+	}
+}
+
+func TestSelectAgentDefs(t *testing.T) {
+	path := writeP(t, `build:
+	Read BACKLOG.md and build one item.
+
+agent-builder:
+	loop(build)
+
+agent-reviewer:
+	loop(build)
+`)
+	defs, err := loadAgentDefs(path, DefaultAgentPrefix)
+	if err != nil {
+		t.Fatalf("loadAgentDefs: %v", err)
+	}
+	if len(defs) != 2 {
+		t.Fatalf("expected 2 agents in file, got %d", len(defs))
+	}
+
+	sent, err := selectAgentDefs(defs, []string{"reviewer"})
+	if err != nil {
+		t.Fatalf("selectAgentDefs: %v", err)
+	}
+	if len(sent) != 1 || sent[0].Name != "reviewer" {
+		t.Fatalf("expected only reviewer to be sent, got %+v", sent)
+	}
+
+	if _, err := selectAgentDefs(defs, []string{"reviewer", "missing"}); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected an error naming the missing agent, got %v", err)
+	}
+}
+
+func TestFormatConfig(t *testing.T) {
+	out := formatConfig(cluster.MasterConfig{
+		Addr:            "127.0.0.1:43252",
+		Model:           "opus",
+		UnhealthyWindow: 5,
+		Limits:          cluster.ApplyLimits{MaxMethods: 64},
+	})
+	for _, want := range []string{
+		"addr:                 127.0.0.1:43252\n",
+		"model:                opus\n",
+		"webhook:              -\n",
+		"max-methods:          64\n",
+		"max-pipeline-steps:   none\n",
+		"max-failures:         none\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("config output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -72,7 +72,7 @@ func main() {
 		}
 	}
 
-	output := sexp.EmitProgram(allNodes, reg, filter)
+	output := sexp.EmitProgram(allNodes, reg, filter, sexp.DefaultAgentPrefix)
 	fmt.Print(output)
 }
 
@@ -3,17 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
 	"syscall"
 
-	"p2p/compiler"
 	"p2p/debug"
-	"p2p/parser"
-	"p2p/registry"
 	"p2p/runtime"
-	"p2p/stdlib"
 )
 
 func main() {
@@ -23,6 +20,8 @@ func main() {
 
 	args := os.Args[1:]
 	var evalExpr string
+	var modelFallback []string
+	var promptVia runtime.PromptMode
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "-d":
@@ -37,149 +36,55 @@ func main() {
 			evalExpr = args[i+1]
 			args = append(args[:i], args[i+2:]...)
 			i--
+		case "--model-fallback":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--model-fallback requires a comma-separated model list\n")
+				os.Exit(1)
+			}
+			modelFallback = strings.Split(args[i+1], ",")
+			args = append(args[:i], args[i+2:]...)
+			i--
+		case "--prompt-via":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "--prompt-via requires stdin, arg or tempfile\n")
+				os.Exit(1)
+			}
+			mode, err := runtime.ParsePromptMode(args[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			promptVia = mode
+			args = append(args[:i], args[i+2:]...)
+			i--
 		}
 	}
 
 	if len(args) < 1 {
-		fmt.Fprintf(os.Stderr, "usage: gprompt [-d] [-e expr] <file.p>\n")
+		fmt.Fprintf(os.Stderr, "usage: gprompt [-d] [-e expr] [--model-fallback m1,m2] [--prompt-via stdin|arg|tempfile] <file.p>\n")
 		os.Exit(1)
 	}
 
-	filename := args[0]
-
-	debug.Log("parsing %s", filename)
-
-	// Parse the input file
-	nodes, err := parser.Parse(filename)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "parse error: %v\n", err)
+	out := &countingWriter{w: os.Stdout}
+	if err := runtime.RunFile(ctx, args[0], runtime.Options{Eval: evalExpr, ModelFallback: modelFallback, PromptVia: promptVia, Output: out}); err != nil {
+		fmt.Fprintf(os.Stderr, "\n%v\n", err)
 		os.Exit(1)
 	}
-	debug.Log("parsed %d nodes", len(nodes))
-	for i, n := range nodes {
-		debug.Log("  node[%d] type=%d name=%q", i, n.Type, n.Name)
-	}
-
-	// Create registry and auto-load stdlib
-	reg := registry.New()
-	loadStdlib(reg, filename)
-
-	// Process nodes: register methods, handle imports, collect execution nodes
-	fileDir := filepath.Dir(filename)
-	var execNodes []parser.Node
-
-	for _, node := range nodes {
-		switch node.Type {
-		case parser.NodeMethodDef:
-			debug.Log("register method %q params=%v", node.Name, node.Params)
-			reg.Register(node.Name, node.Params, node.Body)
-		case parser.NodeImport:
-			importPath := resolveImport(node.ImportPath, fileDir)
-			importNodes, err := parser.Parse(importPath)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "import error (%s): %v\n", node.ImportPath, err)
-				os.Exit(1)
-			}
-			for _, n := range importNodes {
-				if n.Type == parser.NodeMethodDef {
-					reg.Register(n.Name, n.Params, n.Body)
-				}
-			}
-		default:
-			execNodes = append(execNodes, node)
-		}
-	}
-
-	// If -e was given, parse it as the exec nodes instead
-	if evalExpr != "" {
-		debug.Log("eval: %s", evalExpr)
-		evalNodes, err := parser.ParseString(evalExpr)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "eval parse error: %v\n", err)
-			os.Exit(1)
-		}
-		execNodes = nil
-		for _, n := range evalNodes {
-			if n.Type != parser.NodeMethodDef {
-				execNodes = append(execNodes, n)
-			}
-		}
-	}
-
-	// Compile into a plan
-	debug.Log("compiling %d exec nodes", len(execNodes))
-	plan := compiler.Compile(execNodes, reg)
-
-	switch plan.Kind {
-	case compiler.PlanPrompt:
-		if plan.Prompt == "" {
-			return
-		}
-		debug.LogPrompt("COMPILED", 1, plan.Prompt)
-		if err := runtime.Execute(ctx, plan.Prompt); err != nil {
-			fmt.Fprintf(os.Stderr, "\nruntime error: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Println()
-
-	case compiler.PlanPipeline:
-		debug.Log("executing pipeline with %d steps, args=%v", len(plan.Pipeline.Steps), plan.Args)
-		if err := runtime.ExecutePipeline(ctx, plan.Pipeline, plan.Args, reg, plan.Preamble); err != nil {
-			fmt.Fprintf(os.Stderr, "\npipeline error: %v\n", err)
-			os.Exit(1)
-		}
+	// End the output with a newline, but print nothing for a plan that
+	// wrote nothing (e.g. a file with only definitions).
+	if out.n > 0 {
 		fmt.Println()
 	}
 }
 
-func loadStdlib(reg *registry.Registry, inputFile string) {
-	inputDir := filepath.Dir(inputFile)
-	exePath, _ := os.Executable()
-	exeDir := filepath.Dir(exePath)
-
-	paths := []string{
-		filepath.Join(inputDir, "stdlib.p"),
-		"stdlib.p",
-		filepath.Join(exeDir, "stdlib.p"),
-	}
-
-	for _, p := range paths {
-		debug.Log("stdlib search: %s", p)
-		if _, err := os.Stat(p); err == nil {
-			debug.Log("stdlib found: %s", p)
-			nodes, err := parser.Parse(p)
-			if err != nil {
-				debug.Log("stdlib parse error: %v", err)
-				continue
-			}
-			for _, n := range nodes {
-				if n.Type == parser.NodeMethodDef {
-					debug.Log("stdlib method: %q", n.Name)
-					reg.Register(n.Name, n.Params, n.Body)
-				}
-			}
-			return
-		}
-	}
-
-	// Fallback: embedded stdlib
-	debug.Log("stdlib not found on disk, using embedded")
-	nodes, err := parser.ParseString(stdlib.Source)
-	if err != nil {
-		debug.Log("embedded stdlib parse error: %v", err)
-		return
-	}
-	for _, n := range nodes {
-		if n.Type == parser.NodeMethodDef {
-			debug.Log("stdlib method: %q", n.Name)
-			reg.Register(n.Name, n.Params, n.Body)
-		}
-	}
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
 }
 
-func resolveImport(importPath string, baseDir string) string {
-	if filepath.IsAbs(importPath) {
-		return importPath
-	}
-	return filepath.Join(baseDir, importPath)
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
@@ -11,15 +11,18 @@ const (
 	StepSimple StepKind = iota
 	StepMap
 	StepLoop
+	StepReduce
 )
 
 type Step struct {
-	Label      string   // output name ("book-outline")
-	Method     string   // method to call ("generate-outline")
-	Kind       StepKind // StepSimple, StepMap, or StepLoop
-	MapRef     string   // for map: descriptive name of items
-	MapMethod  string   // for map: method to call per item
-	LoopMethod string   // for loop: method to call each iteration
+	Label        string   // output name ("book-outline")
+	Method       string   // method to call ("generate-outline")
+	Kind         StepKind // StepSimple, StepMap, StepReduce, or StepLoop
+	MapRef       string   // for map: descriptive name of items
+	MapMethod    string   // for map: method to call per item
+	ReduceMethod string   // for reduce: method folding two items into one
+	LoopMethod   string   // for loop: method to call each iteration
+	WarmupMethod string   // for loop: optional method used instead of LoopMethod on iteration 1
 }
 
 type Pipeline struct {
@@ -37,7 +40,7 @@ func IsPipeline(body string) bool {
 }
 
 // Parse splits a pipeline body into its initial input and steps.
-// Format: "input -> label (method) -> label (map(ref, method))"
+// Format: "input -> label (method) -> label (map(ref, method)) -> label (reduce(method))"
 func Parse(body string) (*Pipeline, error) {
 	// Pipeline body should be a single line
 	line := strings.TrimSpace(body)
@@ -48,6 +51,9 @@ func Parse(body string) (*Pipeline, error) {
 		if err != nil {
 			return nil, fmt.Errorf("step 1: %w", err)
 		}
+		if step.Kind == StepReduce {
+			return nil, fmt.Errorf("step 1: reduce needs a previous step to reduce")
+		}
 		return &Pipeline{Steps: []Step{step}}, nil
 	}
 
@@ -72,8 +78,19 @@ func Parse(body string) (*Pipeline, error) {
 	return p, nil
 }
 
-// parseStep parses "label (method)", "label (map(ref, method))", "label (loop(method))",
-// or bare "method" (label and method are the same).
+// loopStep parses the arguments of loop(method) or loop(method, warmup).
+func loopStep(inner string) Step {
+	method, warmup, _ := strings.Cut(inner, ",")
+	return Step{
+		Kind:         StepLoop,
+		LoopMethod:   strings.TrimSpace(method),
+		WarmupMethod: strings.TrimSpace(warmup),
+	}
+}
+
+// parseStep parses "label (method)", "label (map(ref, method))", "label (reduce(method))",
+// "label (loop(method))", "label (loop(method, warmup))", or bare "method" (label and
+// method are the same).
 func parseStep(seg string) (Step, error) {
 	parenIdx := strings.Index(seg, " (")
 	if parenIdx == -1 {
@@ -81,12 +98,9 @@ func parseStep(seg string) (Step, error) {
 
 		// Check for loop(method) without a label
 		if strings.HasPrefix(name, "loop(") && strings.HasSuffix(name, ")") {
-			inner := name[5 : len(name)-1]
-			return Step{
-				Label:      inner,
-				Kind:       StepLoop,
-				LoopMethod: strings.TrimSpace(inner),
-			}, nil
+			step := loopStep(name[5 : len(name)-1])
+			step.Label = step.LoopMethod
+			return step, nil
 		}
 
 		// Check for map(ref, method) without a label
@@ -104,6 +118,12 @@ func parseStep(seg string) (Step, error) {
 			}, nil
 		}
 
+		// Check for reduce(method) without a label
+		if strings.HasPrefix(name, "reduce(") && strings.HasSuffix(name, ")") {
+			method := strings.TrimSpace(name[7 : len(name)-1])
+			return Step{Label: method, Kind: StepReduce, ReduceMethod: method}, nil
+		}
+
 		// Bare word: label = method
 		return Step{
 			Label:  name,
@@ -129,11 +149,9 @@ func parseStep(seg string) (Step, error) {
 		}
 		inner = inner[:len(inner)-1]
 
-		return Step{
-			Label:      label,
-			Kind:       StepLoop,
-			LoopMethod: strings.TrimSpace(inner),
-		}, nil
+		step := loopStep(inner)
+		step.Label = label
+		return step, nil
 	}
 
 	// Check for map(ref, method)
@@ -157,6 +175,19 @@ func parseStep(seg string) (Step, error) {
 		}, nil
 	}
 
+	// Check for reduce(method)
+	if strings.HasPrefix(rest, "reduce(") {
+		inner := rest[7:] // skip "reduce("
+		if !strings.HasSuffix(inner, ")") {
+			return Step{}, fmt.Errorf("step %q malformed reduce expression", seg)
+		}
+		return Step{
+			Label:        label,
+			Kind:         StepReduce,
+			ReduceMethod: strings.TrimSpace(inner[:len(inner)-1]),
+		}, nil
+	}
+
 	return Step{
 		Label:  label,
 		Method: rest,
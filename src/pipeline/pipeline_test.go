@@ -78,3 +78,63 @@ func TestParseLabeledSteps(t *testing.T) {
 		t.Errorf("step 1: got %+v", p.Steps[1])
 	}
 }
+
+func TestParseLoopWarmup(t *testing.T) {
+	p, err := Parse("idea -> plan -> loop(build, bootstrap)")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	loop := p.Steps[1]
+	if loop.Kind != StepLoop || loop.Label != "build" || loop.LoopMethod != "build" || loop.WarmupMethod != "bootstrap" {
+		t.Errorf("bare loop: got %+v", loop)
+	}
+
+	p, err = Parse("idea -> work (loop(build, bootstrap))")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	loop = p.Steps[0]
+	if loop.Label != "work" || loop.LoopMethod != "build" || loop.WarmupMethod != "bootstrap" {
+		t.Errorf("labeled loop: got %+v", loop)
+	}
+
+	p, err = Parse("loop(build)")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if p.Steps[0].WarmupMethod != "" {
+		t.Errorf("loop without warmup: got %+v", p.Steps[0])
+	}
+}
+
+func TestParseReduce(t *testing.T) {
+	p, err := Parse("topic -> ideas -> map(ideas, expand) -> merge")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if p.Steps[2].Kind != StepSimple {
+		t.Errorf("bare word should stay a simple step, got %+v", p.Steps[2])
+	}
+
+	p, err = Parse("topic -> ideas -> map(ideas, expand) -> reduce(merge)")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	red := p.Steps[2]
+	if red.Kind != StepReduce || red.Label != "merge" || red.ReduceMethod != "merge" {
+		t.Errorf("bare reduce: got %+v", red)
+	}
+
+	p, err = Parse("topic -> ideas -> map(ideas, expand) -> summary (reduce(merge))")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	red = p.Steps[2]
+	if red.Kind != StepReduce || red.Label != "summary" || red.ReduceMethod != "merge" {
+		t.Errorf("labeled reduce: got %+v", red)
+	}
+
+	if _, err := Parse("reduce(merge)"); err == nil {
+		t.Error("a reduce with nothing to reduce should be an error")
+	}
+}
@@ -0,0 +1,204 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"p2p/compiler"
+	"p2p/debug"
+	"p2p/parser"
+	"p2p/registry"
+	"p2p/stdlib"
+)
+
+// Options configures RunFile and RunString.
+type Options struct {
+	// Claude replaces the claude CLI: it is called with each prompt and
+	// returns the response. Nil uses the CLI.
+	Claude func(ctx context.Context, prompt string) (string, error)
+	// Eval, if set, is run instead of the program's own top-level
+	// expressions, with the program's methods in scope (gprompt -e).
+	Eval string
+	// Args are extra pipeline arguments. They override arguments of the
+	// same name given in the program.
+	Args map[string]string
+	// Preamble is prepended to the program's own preamble, ahead of the
+	// first pipeline step.
+	Preamble string
+	// Output receives the program's result. Nil means os.Stdout.
+	Output io.Writer
+	// ModelFallback lists models to retry with, in order, when a claude
+	// call fails because the primary model is unavailable. Ignored when
+	// Claude is set.
+	ModelFallback []string
+	// PromptVia is how prompts reach claude. Empty means PromptStdin.
+	// Ignored when Claude is set.
+	PromptVia PromptMode
+}
+
+// RunFile parses, compiles and runs the .p program at path. Imports and
+// stdlib.p are looked up relative to the file's directory.
+func RunFile(ctx context.Context, path string, opts Options) error {
+	debug.Log("parsing %s", path)
+	nodes, err := parser.Parse(path)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	return run(ctx, nodes, filepath.Dir(path), opts)
+}
+
+// RunString is RunFile for a program held in memory. Imports and stdlib.p
+// are looked up relative to the working directory.
+func RunString(ctx context.Context, src string, opts Options) error {
+	nodes, err := parser.ParseString(src)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+	return run(ctx, nodes, ".", opts)
+}
+
+// run loads the stdlib, registers the program's methods and imports,
+// compiles its top-level expressions (or opts.Eval) and executes the plan.
+func run(ctx context.Context, nodes []parser.Node, dir string, opts Options) error {
+	debug.Log("parsed %d nodes", len(nodes))
+	for i, n := range nodes {
+		debug.Log("  node[%d] type=%d name=%q", i, n.Type, n.Name)
+	}
+
+	// Create registry and auto-load stdlib
+	reg := registry.New()
+	loadStdlib(reg, dir)
+
+	// Process nodes: register methods, handle imports, collect execution nodes
+	var execNodes []parser.Node
+	for _, node := range nodes {
+		switch node.Type {
+		case parser.NodeMethodDef:
+			debug.Log("register method %q params=%v", node.Name, node.Params)
+			reg.Register(node.Name, node.Params, node.Body)
+		case parser.NodeImport:
+			importPath := resolveImport(node.ImportPath, dir)
+			importNodes, err := parser.Parse(importPath)
+			if err != nil {
+				return fmt.Errorf("import error (%s): %w", node.ImportPath, err)
+			}
+			for _, n := range importNodes {
+				if n.Type == parser.NodeMethodDef {
+					reg.Register(n.Name, n.Params, n.Body)
+				}
+			}
+		default:
+			execNodes = append(execNodes, node)
+		}
+	}
+
+	// If an eval expression was given, parse it as the exec nodes instead
+	if opts.Eval != "" {
+		debug.Log("eval: %s", opts.Eval)
+		evalNodes, err := parser.ParseString(opts.Eval)
+		if err != nil {
+			return fmt.Errorf("eval parse error: %w", err)
+		}
+		execNodes = nil
+		for _, n := range evalNodes {
+			if n.Type != parser.NodeMethodDef {
+				execNodes = append(execNodes, n)
+			}
+		}
+	}
+
+	// Compile into a plan
+	debug.Log("compiling %d exec nodes", len(execNodes))
+	plan := compiler.Compile(execNodes, reg)
+	r := newRunner(opts)
+
+	switch plan.Kind {
+	case compiler.PlanPrompt:
+		if plan.Prompt == "" {
+			return nil
+		}
+		debug.LogPrompt("COMPILED", 1, plan.Prompt)
+		if err := r.execute(ctx, plan.Prompt); err != nil {
+			return fmt.Errorf("runtime error: %w", err)
+		}
+
+	case compiler.PlanPipeline:
+		args := make(map[string]string, len(plan.Args)+len(opts.Args))
+		for k, v := range plan.Args {
+			args[k] = v
+		}
+		for k, v := range opts.Args {
+			args[k] = v
+		}
+		preamble := plan.Preamble
+		if opts.Preamble != "" {
+			if preamble != "" {
+				preamble = opts.Preamble + "\n\n" + preamble
+			} else {
+				preamble = opts.Preamble
+			}
+		}
+		debug.Log("executing pipeline with %d steps, args=%v", len(plan.Pipeline.Steps), args)
+		if err := r.executePipeline(ctx, plan.Pipeline, args, reg, preamble); err != nil {
+			return fmt.Errorf("pipeline error: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadStdlib registers the standard library's methods, preferring a
+// stdlib.p next to the program, then in the working directory, then next
+// to the executable, and falling back to the embedded copy.
+func loadStdlib(reg *registry.Registry, dir string) {
+	exePath, _ := os.Executable()
+	exeDir := filepath.Dir(exePath)
+
+	paths := []string{
+		filepath.Join(dir, "stdlib.p"),
+		"stdlib.p",
+		filepath.Join(exeDir, "stdlib.p"),
+	}
+
+	for _, p := range paths {
+		debug.Log("stdlib search: %s", p)
+		if _, err := os.Stat(p); err == nil {
+			debug.Log("stdlib found: %s", p)
+			nodes, err := parser.Parse(p)
+			if err != nil {
+				debug.Log("stdlib parse error: %v", err)
+				continue
+			}
+			for _, n := range nodes {
+				if n.Type == parser.NodeMethodDef {
+					debug.Log("stdlib method: %q", n.Name)
+					reg.Register(n.Name, n.Params, n.Body)
+				}
+			}
+			return
+		}
+	}
+
+	// Fallback: embedded stdlib
+	debug.Log("stdlib not found on disk, using embedded")
+	nodes, err := parser.ParseString(stdlib.Source)
+	if err != nil {
+		debug.Log("embedded stdlib parse error: %v", err)
+		return
+	}
+	for _, n := range nodes {
+		if n.Type == parser.NodeMethodDef {
+			debug.Log("stdlib method: %q", n.Name)
+			reg.Register(n.Name, n.Params, n.Body)
+		}
+	}
+}
+
+func resolveImport(importPath string, baseDir string) string {
+	if filepath.IsAbs(importPath) {
+		return importPath
+	}
+	return filepath.Join(baseDir, importPath)
+}
@@ -21,13 +21,69 @@ import (
 
 // Execute sends a compiled prompt to the claude CLI (streaming to stdout).
 func Execute(ctx context.Context, prompt string) error {
-	debug.LogPrompt("EXEC", 1, prompt)
-	_, err := callClaude(ctx, prompt)
-	return err
+	return newRunner(Options{}).execute(ctx, prompt)
 }
 
 // ExecutePipeline runs a multi-step pipeline, calling claude for each step.
 func ExecutePipeline(ctx context.Context, p *pipeline.Pipeline, args map[string]string, reg *registry.Registry, preamble string) error {
+	return newRunner(Options{}).executePipeline(ctx, p, args, reg, preamble)
+}
+
+// ClaudeConfig configures calls to the claude CLI. The zero value uses
+// MODEL from the environment with no fallback.
+type ClaudeConfig struct {
+	// Model pins the primary model, overriding MODEL.
+	Model string
+	// ModelFallback lists models to retry with, in order, when a call
+	// fails because the primary model is unavailable. Empty means
+	// primary-only.
+	ModelFallback []string
+	// PromptVia is how prompts reach claude. Empty means PromptStdin.
+	PromptVia PromptMode
+}
+
+// runner carries the claude calls and output writer a program runs with.
+type runner struct {
+	// show calls claude and writes its response to out as it arrives.
+	show func(ctx context.Context, prompt string) (string, error)
+	// capture calls claude without writing anything.
+	capture func(ctx context.Context, prompt string) (string, error)
+	out     io.Writer
+}
+
+func newRunner(opts Options) *runner {
+	r := &runner{out: opts.Output}
+	if r.out == nil {
+		r.out = os.Stdout
+	}
+	if opts.Claude != nil {
+		r.capture = opts.Claude
+		r.show = func(ctx context.Context, prompt string) (string, error) {
+			result, err := opts.Claude(ctx, prompt)
+			if err == nil {
+				fmt.Fprint(r.out, result)
+			}
+			return result, err
+		}
+		return r
+	}
+	cfg := ClaudeConfig{ModelFallback: opts.ModelFallback, PromptVia: opts.PromptVia}
+	r.capture = func(ctx context.Context, prompt string) (string, error) {
+		return callClaudeCapture(ctx, cfg, prompt)
+	}
+	r.show = func(ctx context.Context, prompt string) (string, error) {
+		return callClaudeTo(ctx, cfg, prompt, r.out)
+	}
+	return r
+}
+
+func (r *runner) execute(ctx context.Context, prompt string) error {
+	debug.LogPrompt("EXEC", 1, prompt)
+	_, err := r.show(ctx, prompt)
+	return err
+}
+
+func (r *runner) executePipeline(ctx context.Context, p *pipeline.Pipeline, args map[string]string, reg *registry.Registry, preamble string) error {
 	vars := make(map[string]string)
 
 	// Seed context with initial input from args (if any)
@@ -47,9 +103,13 @@ func ExecutePipeline(ctx context.Context, p *pipeline.Pipeline, args map[string]
 		debug.Log("pipeline: preamble = %q", preamble)
 	}
 
+	// prevItems holds a map step's results for a following map or reduce.
+	var prevItems []string
 	for i, step := range p.Steps {
 		stepNum := i + 1
 		isLast := i == len(p.Steps)-1
+		mapped := prevItems
+		prevItems = nil
 
 		switch step.Kind {
 		case pipeline.StepSimple:
@@ -74,9 +134,9 @@ func ExecutePipeline(ctx context.Context, p *pipeline.Pipeline, args map[string]
 			var result string
 			var err error
 			if isLast {
-				result, err = callClaude(ctx, prompt)
+				result, err = r.show(ctx, prompt)
 			} else {
-				result, err = CallClaudeCapture(ctx, prompt)
+				result, err = r.capture(ctx, prompt)
 			}
 			if err != nil {
 				return fmt.Errorf("step %d (%s): %w", stepNum, step.Label, err)
@@ -92,7 +152,10 @@ func ExecutePipeline(ctx context.Context, p *pipeline.Pipeline, args map[string]
 				return fmt.Errorf("step %d: unknown map method %q", stepNum, step.MapMethod)
 			}
 
-			items := splitItems(prevOutput)
+			items := mapped
+			if items == nil {
+				items = splitItems(prevOutput)
+			}
 			debug.Log("pipeline: map step %d split into %d items", stepNum, len(items))
 
 			if len(items) == 0 {
@@ -117,7 +180,7 @@ func ExecutePipeline(ctx context.Context, p *pipeline.Pipeline, args map[string]
 				go func(idx int) {
 					defer wg.Done()
 
-					result, err := CallClaudeCapture(mapCtx, prompts[idx])
+					result, err := r.capture(mapCtx, prompts[idx])
 
 					mu.Lock()
 					defer mu.Unlock()
@@ -133,29 +196,80 @@ func ExecutePipeline(ctx context.Context, p *pipeline.Pipeline, args map[string]
 				return fmt.Errorf("step %d (%s): %w", stepNum, step.Label, firstErr)
 			}
 
-			joined := strings.Join(results, "\n\n---\n\n")
+			joined := strings.Join(results, mapSeparator)
 			vars[step.Label] = joined
 			prevOutput = joined
+			prevItems = results
 
 			if isLast {
-				fmt.Print(joined)
+				fmt.Fprint(r.out, joined)
 			}
 			debug.Log("pipeline: map step %d collected %d results, stored as %q", stepNum, len(results), step.Label)
 
+		case pipeline.StepReduce:
+			method := reg.Get(step.ReduceMethod)
+			if method == nil {
+				return fmt.Errorf("step %d: unknown reduce method %q", stepNum, step.ReduceMethod)
+			}
+
+			items := mapped
+			if items == nil {
+				items = splitItems(prevOutput)
+			}
+			debug.Log("pipeline: reduce step %d split into %d items", stepNum, len(items))
+
+			if len(items) == 0 {
+				return fmt.Errorf("step %d: reduce got 0 items from previous output", stepNum)
+			}
+
+			// Fold left to right, two items per call. Only the last call
+			// of a final step is shown.
+			result := items[0]
+			for j, item := range items[1:] {
+				prompt := result + mapSeparator + item + "\n\n" + method.Body
+				debug.LogPrompt(fmt.Sprintf("PIPELINE REDUCE %d/%d: %s", j+2, len(items), step.ReduceMethod), stepNum, prompt)
+
+				var err error
+				if isLast && j == len(items)-2 {
+					result, err = r.show(ctx, prompt)
+				} else {
+					result, err = r.capture(ctx, prompt)
+				}
+				if err != nil {
+					return fmt.Errorf("step %d (%s): reduce item %d: %w", stepNum, step.Label, j+2, err)
+				}
+			}
+			if isLast && len(items) == 1 {
+				fmt.Fprint(r.out, result)
+			}
+
+			vars[step.Label] = result
+			prevOutput = result
+			debug.Log("pipeline: reduce step %d folded %d items, stored as %q", stepNum, len(items), step.Label)
+
 		case pipeline.StepLoop:
 			method := reg.Get(step.LoopMethod)
 			if method == nil {
 				return fmt.Errorf("step %d: unknown loop method %q", stepNum, step.LoopMethod)
 			}
+			warmup := method
+			if step.WarmupMethod != "" {
+				if warmup = reg.Get(step.WarmupMethod); warmup == nil {
+					return fmt.Errorf("step %d: unknown warmup method %q", stepNum, step.WarmupMethod)
+				}
+			}
 
 			iteration := 0
 			for {
 				iteration++
 				prompt := method.Body
+				if iteration == 1 {
+					prompt = warmup.Body
+				}
 
 				debug.LogPrompt(fmt.Sprintf("PIPELINE LOOP %d iter %d: %s", stepNum, iteration, step.LoopMethod), stepNum, prompt)
 
-				result, err := callClaude(ctx, prompt)
+				result, err := r.show(ctx, prompt)
 				if err != nil {
 					return fmt.Errorf("step %d (%s) iter %d: %w", stepNum, step.Label, iteration, err)
 				}
@@ -172,26 +286,105 @@ func ExecutePipeline(ctx context.Context, p *pipeline.Pipeline, args map[string]
 	return nil
 }
 
+// claudeBin is the claude executable. Tests point it at a fake command.
+var claudeBin = "claude"
+
+// defaultModel is used when MODEL is not set in the environment.
+const defaultModel = "claude-opus-4-6"
+
+// modelChain returns the primary model followed by any ModelFallback
+// entries not already in the chain. The primary is c.Model if non-empty,
+// else MODEL from the environment, else the built-in default.
+func (c ClaudeConfig) modelChain() []string {
+	primary := c.Model
+	if primary == "" {
+		primary = os.Getenv("MODEL")
+	}
+	if primary == "" {
+		primary = defaultModel
+	}
+	chain := []string{primary}
+	for _, m := range c.ModelFallback {
+		m = strings.TrimSpace(m)
+		if m == "" || contains(chain, m) {
+			continue
+		}
+		chain = append(chain, m)
+	}
+	return chain
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// modelUnavailableMarkers are substrings of claude's error output that
+// indicate the requested model can't serve the request.
+var modelUnavailableMarkers = []string{
+	"model not found",
+	"not_found_error",
+	"unknown model",
+	"invalid model",
+	"model is not available",
+	"model unavailable",
+	"overloaded_error",
+}
+
+// isModelUnavailable reports whether claude's output looks like a
+// model-unavailability failure (as opposed to e.g. a bad prompt).
+func isModelUnavailable(output string) bool {
+	lower := strings.ToLower(output)
+	for _, m := range modelUnavailableMarkers {
+		if strings.Contains(lower, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// callWithFallback runs call once per model in the chain, primary first.
+// It moves on to the next model only when the call failed and its output
+// (stdout+stderr, as captured by call) looks like model unavailability.
+func callWithFallback[T any](ctx context.Context, c ClaudeConfig, call func(model string) (result T, diag string, err error)) (T, error) {
+	models := c.modelChain()
+	var zero T
+	var err error
+	for i, model := range models {
+		var result T
+		var diag string
+		result, diag, err = call(model)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil || i == len(models)-1 || !isModelUnavailable(diag) {
+			return zero, err
+		}
+		debug.Log("model %q unavailable, falling back to %q", model, models[i+1])
+	}
+	return zero, err
+}
+
 // claudeCmd builds the base claude command with flags that:
 // - set a system prompt with the working directory so Claude's file tools
 //   operate in the correct location (not the git root)
 // - bypass all permission checks so tools (file read/write) execute without prompting
+// - select the given model
 //
 // The command is bound to ctx: if ctx is cancelled, the entire process group
 // is killed so no orphaned claude (or its children) survive.
-func claudeCmd(ctx context.Context, extraArgs ...string) *exec.Cmd {
+func claudeCmd(ctx context.Context, model string, extraArgs ...string) *exec.Cmd {
 	sysprompt := ""
 	if wd, err := os.Getwd(); err == nil {
 		sysprompt = fmt.Sprintf("Your working directory is %s. All file operations should use this directory, not the git repository root.", wd)
 	}
-	args := []string{"-p", "--system-prompt", sysprompt, "--dangerously-skip-permissions"}
-	if m := os.Getenv("MODEL"); m != "" {
-		args = append(args, "--model", m)
-	} else {
-		args = append(args, "--model", "claude-opus-4-6")
-	}
+	args := []string{"-p", "--system-prompt", sysprompt, "--dangerously-skip-permissions", "--model", model}
 	args = append(args, extraArgs...)
-	cmd := exec.CommandContext(ctx, "claude", args...)
+	cmd := exec.CommandContext(ctx, claudeBin, args...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	cmd.Cancel = func() error {
 		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
@@ -199,6 +392,59 @@ func claudeCmd(ctx context.Context, extraArgs ...string) *exec.Cmd {
 	return cmd
 }
 
+// PromptMode selects how a prompt is handed to the claude command.
+type PromptMode string
+
+const (
+	// PromptStdin writes the prompt to the command's stdin. It is the
+	// default, and what the claude CLI expects; the other modes are for
+	// wrappers and providers that can't read stdin.
+	PromptStdin PromptMode = "stdin"
+	// PromptArg passes the prompt as the final positional argument.
+	PromptArg PromptMode = "arg"
+	// PromptTempfile writes the prompt to a temporary file and passes its
+	// path as the final positional argument. The file is removed once the
+	// command exits.
+	PromptTempfile PromptMode = "tempfile"
+)
+
+// ParsePromptMode validates a prompt delivery mode name.
+func ParsePromptMode(s string) (PromptMode, error) {
+	switch m := PromptMode(s); m {
+	case PromptStdin, PromptArg, PromptTempfile:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown prompt delivery %q (want stdin, arg or tempfile)", s)
+}
+
+// withPrompt attaches prompt to cmd according to mode. The returned
+// cleanup removes any temp file; callers defer it so the file goes away
+// when the call returns, including when ctx cancels the command.
+func withPrompt(cmd *exec.Cmd, mode PromptMode, prompt string) (cleanup func(), err error) {
+	switch mode {
+	case PromptArg:
+		cmd.Args = append(cmd.Args, prompt)
+	case PromptTempfile:
+		f, err := os.CreateTemp("", "gprompt-prompt-*.txt")
+		if err != nil {
+			return nil, fmt.Errorf("prompt tempfile: %w", err)
+		}
+		_, werr := f.WriteString(prompt)
+		if cerr := f.Close(); werr == nil {
+			werr = cerr
+		}
+		if werr != nil {
+			os.Remove(f.Name())
+			return nil, fmt.Errorf("prompt tempfile: %w", werr)
+		}
+		cmd.Args = append(cmd.Args, f.Name())
+		return func() { os.Remove(f.Name()) }, nil
+	default:
+		cmd.Stdin = strings.NewReader(prompt)
+	}
+	return func() {}, nil
+}
+
 // stream event types from claude --output-format stream-json
 type streamEvent struct {
 	Type  string          `json:"type"`
@@ -244,21 +490,43 @@ type streamResult struct {
 	TotalCost float64      `json:"total_cost_usd"`
 }
 
+// usage converts the result's token counts and cost for the cluster.
+// Cached input tokens count as input.
+func (r streamResult) usage() cluster.Usage {
+	u := cluster.Usage{CostUSD: r.TotalCost}
+	if r.Usage != nil {
+		u.InputTokens = r.Usage.InputTokens + r.Usage.CacheCreationInputTokens + r.Usage.CacheReadInputTokens
+		u.OutputTokens = r.Usage.OutputTokens
+	}
+	return u
+}
+
 // callClaudeStream runs claude with --output-format stream-json, parsing events
 // to update the debug footer with live token counts and output preview.
 // Returns the final result text.
-func callClaudeStream(ctx context.Context, prompt string) (string, error) {
-	cmd := claudeCmd(ctx, "--output-format", "stream-json", "--verbose", "--include-partial-messages")
-	cmd.Stdin = strings.NewReader(prompt)
+func callClaudeStream(ctx context.Context, c ClaudeConfig, prompt string) (string, error) {
+	return callWithFallback(ctx, c, func(model string) (string, string, error) {
+		return callClaudeStreamModel(ctx, c, model, prompt)
+	})
+}
+
+func callClaudeStreamModel(ctx context.Context, c ClaudeConfig, model, prompt string) (string, string, error) {
+	cmd := claudeCmd(ctx, model, "--output-format", "stream-json", "--verbose", "--include-partial-messages")
+	cleanup, err := withPrompt(cmd, c.PromptVia, prompt)
+	if err != nil {
+		return "", "", err
+	}
+	defer cleanup()
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	cmd.Stderr = os.Stderr
+	var errBuf bytes.Buffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &errBuf)
 
 	if err := cmd.Start(); err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	var result string
@@ -320,81 +588,115 @@ func callClaudeStream(ctx context.Context, prompt string) (string, error) {
 
 	if err := cmd.Wait(); err != nil {
 		debug.CallEnd(0, 0, 0)
-		return "", err
+		return "", errBuf.String() + result, err
 	}
 
 	debug.CallEnd(inTok, outTok, cost)
-	return strings.TrimSpace(result), nil
+	return strings.TrimSpace(result), "", nil
 }
 
-// callClaude runs claude -p, streaming output to stdout and capturing it.
+// callClaudeTo runs claude -p, streaming output to w and capturing it.
 // In debug mode, uses stream-json to show live token meter.
-func callClaude(ctx context.Context, prompt string) (string, error) {
+func callClaudeTo(ctx context.Context, c ClaudeConfig, prompt string, w io.Writer) (string, error) {
 	if debug.Enabled {
-		result, err := callClaudeStream(ctx, prompt)
+		result, err := callClaudeStream(ctx, c, prompt)
 		if err != nil {
 			return "", err
 		}
-		fmt.Print(result)
+		fmt.Fprint(w, result)
 		return result, nil
 	}
 
-	cmd := claudeCmd(ctx)
-	cmd.Stdin = strings.NewReader(prompt)
-
-	var buf bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &buf)
-	cmd.Stderr = os.Stderr
+	return callWithFallback(ctx, c, func(model string) (string, string, error) {
+		cmd := claudeCmd(ctx, model)
+		cleanup, err := withPrompt(cmd, c.PromptVia, prompt)
+		if err != nil {
+			return "", "", err
+		}
+		defer cleanup()
 
-	err := cmd.Run()
-	if err != nil {
-		return "", err
-	}
+		var buf, errBuf bytes.Buffer
+		cmd.Stdout = io.MultiWriter(w, &buf)
+		cmd.Stderr = io.MultiWriter(os.Stderr, &errBuf)
 
-	return strings.TrimSpace(buf.String()), nil
+		if err := cmd.Run(); err != nil {
+			return "", errBuf.String() + buf.String(), err
+		}
+		return strings.TrimSpace(buf.String()), "", nil
+	})
 }
 
 // CallClaudeCapture runs claude -p, capturing output silently (no stdout streaming).
 // In debug mode, uses stream-json to show live token meter.
 // Exported for use by the cluster executor.
 func CallClaudeCapture(ctx context.Context, prompt string) (string, error) {
+	return callClaudeCapture(ctx, ClaudeConfig{}, prompt)
+}
+
+func callClaudeCapture(ctx context.Context, c ClaudeConfig, prompt string) (string, error) {
 	if debug.Enabled {
-		return callClaudeStream(ctx, prompt)
+		return callClaudeStream(ctx, c, prompt)
 	}
 
-	cmd := claudeCmd(ctx)
-	cmd.Stdin = strings.NewReader(prompt)
+	return callWithFallback(ctx, c, func(model string) (string, string, error) {
+		cmd := claudeCmd(ctx, model)
+		cleanup, err := withPrompt(cmd, c.PromptVia, prompt)
+		if err != nil {
+			return "", "", err
+		}
+		defer cleanup()
 
-	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = os.Stderr
+		var buf, errBuf bytes.Buffer
+		cmd.Stdout = &buf
+		cmd.Stderr = io.MultiWriter(os.Stderr, &errBuf)
 
-	err := cmd.Run()
-	if err != nil {
-		return "", err
-	}
-
-	return strings.TrimSpace(buf.String()), nil
+		if err := cmd.Run(); err != nil {
+			return "", errBuf.String() + buf.String(), err
+		}
+		return strings.TrimSpace(buf.String()), "", nil
+	})
 }
 
 // CallClaudeStreaming runs claude with stream-json output, emitting ConvoMessages
 // via the onMessage callback as events arrive. This is used by the cluster executor
 // to stream live iteration content to the steer TUI.
 func CallClaudeStreaming(ctx context.Context, prompt string, onMessage func(cluster.ConvoMessage)) (string, error) {
-	cmd := claudeCmd(ctx, "--output-format", "stream-json", "--verbose", "--include-partial-messages")
-	cmd.Stdin = strings.NewReader(prompt)
+	res, err := CallClaudeStreamingUsage(ClaudeConfig{})(ctx, prompt, onMessage)
+	return res.Output, err
+}
+
+// CallClaudeStreamingUsage returns a CallClaudeStreaming variant that
+// calls claude as c configures and also returns the token usage and cost
+// claude reports for the call. Used by `gcluster master`.
+func CallClaudeStreamingUsage(c ClaudeConfig) cluster.ClaudeUsageFunc {
+	return func(ctx context.Context, prompt string, onMessage func(cluster.ConvoMessage)) (cluster.ClaudeResult, error) {
+		return callWithFallback(ctx, c, func(m string) (cluster.ClaudeResult, string, error) {
+			return callClaudeStreamingModel(ctx, c, m, prompt, onMessage)
+		})
+	}
+}
+
+func callClaudeStreamingModel(ctx context.Context, c ClaudeConfig, model, prompt string, onMessage func(cluster.ConvoMessage)) (cluster.ClaudeResult, string, error) {
+	cmd := claudeCmd(ctx, model, "--output-format", "stream-json", "--verbose", "--include-partial-messages")
+	cleanup, err := withPrompt(cmd, c.PromptVia, prompt)
+	if err != nil {
+		return cluster.ClaudeResult{}, "", err
+	}
+	defer cleanup()
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return "", err
+		return cluster.ClaudeResult{}, "", err
 	}
-	cmd.Stderr = io.Discard
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
 
 	if err := cmd.Start(); err != nil {
-		return "", err
+		return cluster.ClaudeResult{}, "", err
 	}
 
 	var result string
+	var usage cluster.Usage
 	var msgCounter int
 	scanner := bufio.NewScanner(stdout)
 	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
@@ -416,6 +718,7 @@ func CallClaudeStreaming(ctx context.Context, prompt string, onMessage func(clus
 		var res streamResult
 		if json.Unmarshal(line, &res) == nil && res.Type == "result" {
 			result = res.Result
+			usage = res.usage()
 			continue
 		}
 
@@ -513,10 +816,10 @@ func CallClaudeStreaming(ctx context.Context, prompt string, onMessage func(clus
 	}
 
 	if err := cmd.Wait(); err != nil {
-		return "", err
+		return cluster.ClaudeResult{Usage: usage}, errBuf.String() + result, err
 	}
 
-	return strings.TrimSpace(result), nil
+	return cluster.ClaudeResult{Output: strings.TrimSpace(result), Usage: usage}, "", nil
 }
 
 // toolDetail extracts a short summary from tool input JSON for display.
@@ -566,33 +869,43 @@ func toolDetail(toolName, inputJSON string) string {
 }
 
 // callClaudeJSON runs claude -p --output-format json and extracts the result field.
-func callClaudeJSON(ctx context.Context, prompt string) (string, error) {
+func callClaudeJSON(ctx context.Context, c ClaudeConfig, prompt string) (string, error) {
 	if debug.Enabled {
-		return callClaudeStream(ctx, prompt)
+		return callClaudeStream(ctx, c, prompt)
 	}
 
-	cmd := claudeCmd(ctx, "--output-format", "json")
-	cmd.Stdin = strings.NewReader(prompt)
-
-	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = os.Stderr
+	return callWithFallback(ctx, c, func(model string) (string, string, error) {
+		cmd := claudeCmd(ctx, model, "--output-format", "json")
+		cleanup, err := withPrompt(cmd, c.PromptVia, prompt)
+		if err != nil {
+			return "", "", err
+		}
+		defer cleanup()
 
-	err := cmd.Run()
-	if err != nil {
-		return "", err
-	}
+		var buf, errBuf bytes.Buffer
+		cmd.Stdout = &buf
+		cmd.Stderr = io.MultiWriter(os.Stderr, &errBuf)
 
-	var resp struct {
-		Result string `json:"result"`
-	}
-	if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
-		return strings.TrimSpace(buf.String()), nil
-	}
+		if err := cmd.Run(); err != nil {
+			return "", errBuf.String() + buf.String(), err
+		}
 
-	return strings.TrimSpace(resp.Result), nil
+		var resp struct {
+			Result string `json:"result"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+			return strings.TrimSpace(buf.String()), "", nil
+		}
+		return strings.TrimSpace(resp.Result), "", nil
+	})
 }
 
+// mapSeparator joins the results of a map step into one output. A map or
+// reduce step right after a map step takes the results as its items
+// directly rather than splitting the joined text, which may itself
+// contain markdown rules.
+const mapSeparator = "\n\n---\n\n"
+
 // splitItems splits text into items using heuristics:
 // tries numbered lists, markdown headings, bullet points, then paragraphs.
 func splitItems(text string) []string {
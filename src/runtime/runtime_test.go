@@ -0,0 +1,262 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeClaudeBin writes an executable shell script standing in for the
+// claude CLI and points claudeBin at it for the duration of the test.
+func fakeClaudeBin(t *testing.T, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := claudeBin
+	claudeBin = path
+	t.Cleanup(func() { claudeBin = old })
+}
+
+// modelArg is a shell snippet that sets $model to the --model argument.
+const modelArg = `
+model=""
+while [ $# -gt 0 ]; do
+	if [ "$1" = "--model" ]; then model="$2"; fi
+	shift
+done
+`
+
+func TestModelFallback(t *testing.T) {
+	fakeClaudeBin(t, modelArg+`
+if [ "$model" = "primary" ]; then
+	echo "API Error: model not found: primary" >&2
+	exit 1
+fi
+echo "answered by $model"
+`)
+	t.Setenv("MODEL", "primary")
+
+	out, err := callClaudeCapture(context.Background(), ClaudeConfig{ModelFallback: []string{"backup"}}, "hello")
+	if err != nil {
+		t.Fatalf("expected fallback to succeed, got %v", err)
+	}
+	if out != "answered by backup" {
+		t.Fatalf("expected backup model to answer, got %q", out)
+	}
+}
+
+func TestModelFallbackPrimaryOnlyByDefault(t *testing.T) {
+	fakeClaudeBin(t, modelArg+`
+echo "API Error: model not found: $model" >&2
+exit 1
+`)
+	t.Setenv("MODEL", "primary")
+
+	if _, err := CallClaudeCapture(context.Background(), "hello"); err == nil {
+		t.Fatal("expected failure with no fallback configured")
+	}
+}
+
+func TestModelFallbackSkipsOtherErrors(t *testing.T) {
+	fakeClaudeBin(t, modelArg+`
+if [ "$model" = "primary" ]; then
+	echo "prompt is too long" >&2
+	exit 1
+fi
+echo "answered by $model"
+`)
+	t.Setenv("MODEL", "primary")
+
+	_, err := callClaudeCapture(context.Background(), ClaudeConfig{ModelFallback: []string{"backup"}}, "hello")
+	if err == nil {
+		t.Fatal("non-model errors should not trigger fallback")
+	}
+}
+
+func TestRunStringModelFallback(t *testing.T) {
+	fakeClaudeBin(t, modelArg+`
+if [ "$model" = "primary" ]; then
+	echo "API Error: model not found: primary" >&2
+	exit 1
+fi
+printf "answered by $model"
+`)
+	t.Setenv("MODEL", "primary")
+
+	var out strings.Builder
+	err := RunString(context.Background(), "say hi", Options{Output: &out, ModelFallback: []string{"backup"}})
+	if err != nil {
+		t.Fatalf("RunString: %v", err)
+	}
+	if got := out.String(); got != "answered by backup" {
+		t.Fatalf("expected the fallback model to answer, got %q", got)
+	}
+}
+
+func TestModelChain(t *testing.T) {
+	t.Setenv("MODEL", "")
+	c := ClaudeConfig{ModelFallback: []string{"a", " ", defaultModel, "b"}}
+
+	got := strings.Join(c.modelChain(), ",")
+	if want := defaultModel + ",a,b"; got != want {
+		t.Fatalf("modelChain = %q, want %q", got, want)
+	}
+}
+
+func TestModelChainPinnedOverridesEnv(t *testing.T) {
+	t.Setenv("MODEL", "from-env")
+
+	if got := (ClaudeConfig{}).modelChain()[0]; got != "from-env" {
+		t.Errorf("unpinned primary = %q, want MODEL env", got)
+	}
+	if got := (ClaudeConfig{Model: "pinned"}).modelChain()[0]; got != "pinned" {
+		t.Errorf("pinned primary = %q, want pinned", got)
+	}
+}
+
+func TestCallClaudeStreamingUsage(t *testing.T) {
+	fakeClaudeBin(t, `
+echo '{"type":"result","result":"done","total_cost_usd":0.25,"usage":{"input_tokens":10,"cache_read_input_tokens":90,"output_tokens":40}}'
+`)
+	res, err := CallClaudeStreamingUsage(ClaudeConfig{})(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatalf("CallClaudeStreamingUsage: %v", err)
+	}
+	if res.Output != "done" {
+		t.Errorf("output = %q, want done", res.Output)
+	}
+	if res.InputTokens != 100 || res.OutputTokens != 40 || res.CostUSD != 0.25 {
+		t.Errorf("usage = %+v, want 100 in (cache reads included), 40 out, $0.25", res.Usage)
+	}
+}
+
+func TestPromptDelivery(t *testing.T) {
+	// Reports what arrived on stdin, the last argument, and the contents
+	// of the last argument if it names a file.
+	fakeClaudeBin(t, `
+for last; do :; done
+echo "stdin=[$(cat)] last=[$last]"
+if [ -f "$last" ]; then echo "file=[$(cat "$last")]"; fi
+`)
+	call := func(mode PromptMode) string {
+		t.Helper()
+		out, err := callClaudeCapture(context.Background(), ClaudeConfig{PromptVia: mode}, "hello prompt")
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		return out
+	}
+
+	if out := call(PromptStdin); !strings.HasPrefix(out, "stdin=[hello prompt] last=[") || strings.Contains(out, "last=[hello prompt]") {
+		t.Errorf("stdin: prompt should arrive on stdin only, got %q", out)
+	}
+	if out := call(PromptArg); out != "stdin=[] last=[hello prompt]" {
+		t.Errorf("arg: prompt should arrive as the last argument, got %q", out)
+	}
+
+	out := call(PromptTempfile)
+	if !strings.HasPrefix(out, "stdin=[] last=[") || !strings.HasSuffix(out, "file=[hello prompt]") {
+		t.Fatalf("tempfile: prompt should arrive in a file named by the last argument, got %q", out)
+	}
+	path := strings.TrimPrefix(strings.SplitN(out, "]", 3)[1], " last=[")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("tempfile %s should be removed after the call, stat err = %v", path, err)
+	}
+}
+
+func TestParsePromptMode(t *testing.T) {
+	for _, s := range []string{"stdin", "arg", "tempfile"} {
+		if m, err := ParsePromptMode(s); err != nil || string(m) != s {
+			t.Errorf("ParsePromptMode(%q) = %q, %v", s, m, err)
+		}
+	}
+	if _, err := ParsePromptMode("pipe"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestRunString(t *testing.T) {
+	var prompts []string
+	claude := func(ctx context.Context, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return fmt.Sprintf("reply %d", len(prompts)), nil
+	}
+	src := `summarise(topic):
+	Write one line about [topic].
+
+polish:
+	Make it punchier.
+
+pitch(topic):
+	topic -> line (summarise) -> final (polish)
+
+@pitch(gophers)
+`
+	var out strings.Builder
+	err := RunString(context.Background(), src, Options{Claude: claude, Output: &out, Preamble: "Be brief."})
+	if err != nil {
+		t.Fatalf("RunString: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("expected 2 claude calls, got %d: %q", len(prompts), prompts)
+	}
+	if !strings.HasPrefix(prompts[0], "Be brief.") || !strings.Contains(prompts[0], "Write one line about gophers.") {
+		t.Errorf("first step prompt = %q, want the preamble and the interpolated body", prompts[0])
+	}
+	if !strings.HasPrefix(prompts[1], "reply 1") {
+		t.Errorf("second step should see the first step's output, got %q", prompts[1])
+	}
+	if out.String() != "reply 2" {
+		t.Errorf("output = %q, want only the last step's reply", out.String())
+	}
+
+	claude = func(ctx context.Context, prompt string) (string, error) { return "", fmt.Errorf("offline") }
+	err = RunString(context.Background(), src, Options{Claude: claude, Output: &out})
+	if err == nil || !strings.Contains(err.Error(), "offline") {
+		t.Errorf("expected the claude error to be returned, got %v", err)
+	}
+}
+
+func TestRunStringMapReduce(t *testing.T) {
+	var mu sync.Mutex
+	claude := func(ctx context.Context, prompt string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(prompt, "List three animals."):
+			return "1. cat\n2. dog\n3. owl", nil
+		case strings.HasSuffix(prompt, "Name it."):
+			return strings.Fields(prompt)[1], nil
+		default:
+			pair := strings.Split(strings.TrimSuffix(prompt, "\n\nJoin them."), mapSeparator)
+			return strings.Join(pair, "&"), nil
+		}
+	}
+	src := `animals:
+	List three animals.
+
+name:
+	Name it.
+
+join:
+	Join them.
+
+zoo(topic):
+	topic -> animals -> map(animals, name) -> reduce(join)
+
+@zoo(pets)
+`
+	var out strings.Builder
+	if err := RunString(context.Background(), src, Options{Claude: claude, Output: &out}); err != nil {
+		t.Fatalf("RunString: %v", err)
+	}
+	if out.String() != "cat&dog&owl" {
+		t.Errorf("output = %q, want the three map results folded into one", out.String())
+	}
+}
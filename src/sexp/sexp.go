@@ -10,9 +10,14 @@ import (
 	"p2p/registry"
 )
 
+// DefaultAgentPrefix is the method-name prefix that marks agent definitions.
+const DefaultAgentPrefix = "agent-"
+
 // EmitProgram emits a (program ...) S-expression from parsed nodes.
 // If filter is non-empty, only the matching top-level definition is emitted.
-func EmitProgram(nodes []parser.Node, reg *registry.Registry, filter string) string {
+// Methods whose names start with agentPrefix are emitted as (defagent ...)
+// forms named without the prefix.
+func EmitProgram(nodes []parser.Node, reg *registry.Registry, filter, agentPrefix string) string {
 	var forms []string
 	for _, node := range nodes {
 		if filter != "" {
@@ -20,7 +25,7 @@ func EmitProgram(nodes []parser.Node, reg *registry.Registry, filter string) str
 				continue
 			}
 		}
-		sexpr := emitNode(node, reg)
+		sexpr := emitNode(node, reg, agentPrefix)
 		if sexpr == "" {
 			continue
 		}
@@ -56,10 +61,10 @@ func EmitProgram(nodes []parser.Node, reg *registry.Registry, filter string) str
 	return strings.Join(lines, "\n")
 }
 
-func emitNode(node parser.Node, reg *registry.Registry) string {
+func emitNode(node parser.Node, reg *registry.Registry, agentPrefix string) string {
 	switch node.Type {
 	case parser.NodeMethodDef:
-		return emitMethodDef(node, reg)
+		return emitMethodDef(node, reg, agentPrefix)
 	case parser.NodeInvocation:
 		return emitInvocation(node)
 	case parser.NodeImport:
@@ -70,13 +75,13 @@ func emitNode(node parser.Node, reg *registry.Registry) string {
 	return ""
 }
 
-func emitMethodDef(node parser.Node, reg *registry.Registry) string {
+func emitMethodDef(node parser.Node, reg *registry.Registry, agentPrefix string) string {
 	name := node.Name
 	m := reg.Get(name)
 
-	// Agent definition: agent- prefix
-	if strings.HasPrefix(name, "agent-") {
-		agentName := strings.TrimPrefix(name, "agent-")
+	// Agent definition: agent prefix
+	if agentPrefix != "" && strings.HasPrefix(name, agentPrefix) {
+		agentName := strings.TrimPrefix(name, agentPrefix)
 		if m != nil && m.IsPipeline {
 			return fmt.Sprintf("(defagent %q\n%s)", agentName, indent(emitPipeline(m.Pipeline), 2))
 		}
@@ -133,8 +138,14 @@ func emitStep(s pipeline.Step) string {
 		action = fmt.Sprintf("(call %s)", s.Method)
 	case pipeline.StepMap:
 		action = fmt.Sprintf("(map %s %s)", s.MapRef, s.MapMethod)
+	case pipeline.StepReduce:
+		action = fmt.Sprintf("(reduce %s)", s.ReduceMethod)
 	case pipeline.StepLoop:
-		action = fmt.Sprintf("(loop %s)", s.LoopMethod)
+		if s.WarmupMethod != "" {
+			action = fmt.Sprintf("(loop %s %s)", s.LoopMethod, s.WarmupMethod)
+		} else {
+			action = fmt.Sprintf("(loop %s)", s.LoopMethod)
+		}
 	}
 	return fmt.Sprintf("(step %q %s)", s.Label, action)
 }
@@ -20,7 +20,7 @@ func parseAndEmit(t *testing.T, source string, filter string) string {
 			reg.Register(n.Name, n.Params, n.Body)
 		}
 	}
-	return EmitProgram(nodes, reg, filter)
+	return EmitProgram(nodes, reg, filter, DefaultAgentPrefix)
 }
 
 func TestYProgram(t *testing.T) {
@@ -98,6 +98,24 @@ func TestAgents(t *testing.T) {
 	}
 }
 
+func TestLoopWarmup(t *testing.T) {
+	source := "build:\n\tDo the build.\n\nsetup:\n\tClone the repo.\n\nagent-builder:\n\tloop(build, setup)\n"
+	output := parseAndEmit(t, source, "")
+
+	if !strings.Contains(output, `(step "build" (loop build setup))`) {
+		t.Errorf("missing warmup in loop step:\n%s", output)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	source := "merge:\n\tMerge these.\n\nsummarise(topic):\n\ttopic -> ideas -> map(ideas, expand) -> summary (reduce(merge))\n"
+	output := parseAndEmit(t, source, "")
+
+	if !strings.Contains(output, `(step "summary" (reduce merge))`) {
+		t.Errorf("missing reduce step:\n%s", output)
+	}
+}
+
 func TestIDCommentsPresent(t *testing.T) {
 	source := "foo:\n\tdo stuff\n\n@foo\n"
 	output := parseAndEmit(t, source, "")